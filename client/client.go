@@ -1,18 +1,30 @@
 package client
 
 import (
-	"bufio"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tashima42/tcp-chat/protocol"
 	"github.com/tashima42/tcp-chat/types"
 	"github.com/urfave/cli/v2"
 )
 
 const (
-	protocol = "tcp"
+	network = "tcp"
+	// minReconnectDelay and maxReconnectDelay bound the exponential backoff
+	// between reconnection attempts.
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+	// historyPageSize is the number of older messages requested when scrolling
+	// past the top of a room.
+	historyPageSize = 50
 )
 
 func Command() *cli.Command {
@@ -26,6 +38,28 @@ func Command() *cli.Command {
 				Aliases:  []string{"a"},
 				Required: true,
 			},
+			&cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "PEM client certificate file for mutual TLS, enables TLS",
+			},
+			&cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "PEM private key file of --tls-cert",
+			},
+			&cli.StringFlag{
+				Name:  "tls-ca",
+				Usage: "PEM CA bundle used to verify the server, enables TLS",
+			},
+			&cli.DurationFlag{
+				Name:  "heartbeat-interval",
+				Usage: "how often the server is pinged, 0 disables pings",
+				Value: 30 * time.Second,
+			},
+			&cli.DurationFlag{
+				Name:  "idle-timeout",
+				Usage: "consider the server gone when it sends nothing for this long, 0 disables it",
+				Value: 90 * time.Second,
+			},
 		},
 		Action: clientCommand,
 	}
@@ -33,12 +67,23 @@ func Command() *cli.Command {
 
 func clientCommand(ctx *cli.Context) error {
 	address := ctx.String("address")
-	conn, err := connect(address)
+	var tlsConfig *tls.Config
+	if ctx.IsSet("tls-cert") || ctx.IsSet("tls-key") || ctx.IsSet("tls-ca") {
+		c, err := newTLSConfig(address, ctx.String("tls-cert"), ctx.String("tls-key"), ctx.String("tls-ca"))
+		if err != nil {
+			return err
+		}
+		tlsConfig = c
+	}
+	dial := func() (net.Conn, error) {
+		return connect(address, tlsConfig)
+	}
+	conn, err := dial()
 	if err != nil {
 		return err
 	}
-	p := tea.NewProgram(initialModel(conn), tea.WithAltScreen(), tea.WithMouseCellMotion())
-	go read(*conn, p)
+	p := tea.NewProgram(initialModel(&conn), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	go run(p, conn, dial, ctx.Duration("heartbeat-interval"), ctx.Duration("idle-timeout"))
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Uh oh", err)
 		os.Exit(1)
@@ -47,55 +92,258 @@ func clientCommand(ctx *cli.Context) error {
 	return nil
 }
 
+// actionID numbers the actions sent by this client so errors can be matched
+// to the action that caused them.
+var actionID atomic.Uint64
+
+func nextActionID() string {
+	return strconv.FormatUint(actionID.Add(1), 10)
+}
+
 func wrapAction(actionType types.ActionType, data []byte) []byte {
+	return wrapActionID(actionType, data, nextActionID())
+}
+
+func wrapActionID(actionType types.ActionType, data []byte, id string) []byte {
 	action := types.Action{
 		Type: actionType,
 		Data: data,
+		ID:   id,
 	}
 	actionB, _ := action.MarshalMsg(nil)
 	return actionB
 }
 
-func register(conn net.Conn, username string) {
-	registerMsg := types.User{Username: username}
+// register registers as username and returns the ID of the action.
+func register(conn net.Conn, username, token string) string {
+	registerMsg := types.Register{Username: username, Token: token}
 	registerB, _ := registerMsg.MarshalMsg(nil)
-	actionB := wrapAction(types.ActionTypeRegister, registerB)
+	id := nextActionID()
+	write(conn, wrapActionID(types.ActionTypeRegister, registerB, id))
+	return id
+}
+
+// login logs in as username and returns the ID of the action.
+func login(conn net.Conn, username, password, token string) string {
+	credentials := types.Credentials{Username: username, Password: password, Token: token}
+	credentialsB, _ := credentials.MarshalMsg(nil)
+	id := nextActionID()
+	write(conn, wrapActionID(types.ActionTypeLogin, credentialsB, id))
+	return id
+}
+
+func rename(conn net.Conn, username string) {
+	renameMsg := types.User{Username: username}
+	renameB, _ := renameMsg.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeRename, renameB)
 	write(conn, actionB)
 }
-func sendMessage(conn net.Conn, value string) {
-	msg := types.Message{Value: value}
+
+// sendMessage sends msg to its room. Its nonce is used as the action ID too, so
+// both the ack and any error refer to it.
+func sendMessage(conn net.Conn, msg types.Message) {
+	msgB, _ := msg.MarshalMsg(nil)
+	actionB := wrapActionID(types.ActionTypeMessage, msgB, msg.Nonce)
+	write(conn, actionB)
+}
+
+func sendDirect(conn net.Conn, to, nonce, value string) {
+	msg := types.DirectMessage{To: to, Value: value, Nonce: nonce}
 	msgB, _ := msg.MarshalMsg(nil)
-	actionB := wrapAction(types.ActionTypeMessage, msgB)
+	actionB := wrapActionID(types.ActionTypeDirect, msgB, nonce)
 	write(conn, actionB)
 }
 
-func connect(address string) (*net.Conn, error) {
-	conn, err := net.Dial(protocol, address)
-	return &conn, err
+func editMessage(conn net.Conn, id uint64, value string) {
+	msg := types.Message{ID: id, Value: value}
+	msgB, _ := msg.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeEdit, msgB)
+	write(conn, actionB)
+}
+
+func deleteMessage(conn net.Conn, id uint64) {
+	msg := types.Message{ID: id}
+	msgB, _ := msg.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeDelete, msgB)
+	write(conn, actionB)
+}
+
+func react(conn net.Conn, id uint64, emoji string) {
+	reaction := types.Reaction{MessageID: id, Emoji: emoji}
+	reactionB, _ := reaction.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeReact, reactionB)
+	write(conn, actionB)
+}
+
+func sendTyping(conn net.Conn, room string) {
+	typing := types.Typing{Room: room}
+	typingB, _ := typing.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeTyping, typingB)
+	write(conn, actionB)
+}
+
+func joinRoom(conn net.Conn, name string) {
+	room := types.Room{Name: name}
+	roomB, _ := room.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeJoinRoom, roomB)
+	write(conn, actionB)
+}
+
+func leaveRoom(conn net.Conn, name string) {
+	room := types.Room{Name: name}
+	roomB, _ := room.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeLeaveRoom, roomB)
+	write(conn, actionB)
+}
+
+func requestHistory(conn net.Conn, room string, before uint64) {
+	request := types.HistoryRequest{Room: room, Before: before, Limit: historyPageSize}
+	requestB, _ := request.MarshalMsg(nil)
+	actionB := wrapAction(types.ActionTypeHistory, requestB)
+	write(conn, actionB)
+}
+
+func listRooms(conn net.Conn) {
+	actionB := wrapAction(types.ActionTypeListRooms, nil)
+	write(conn, actionB)
+}
+
+func connect(address string, tlsConfig *tls.Config) (net.Conn, error) {
+	if tlsConfig != nil {
+		return tls.Dial(network, address, tlsConfig)
+	}
+	return net.Dial(network, address)
+}
+
+// errKicked is returned by read when the server kicked the client.
+var errKicked = errors.New("kicked from the server")
+
+// sender receives the messages for the TUI. It is a *tea.Program, except in
+// tests.
+type sender interface {
+	Send(msg tea.Msg)
+}
+
+// run keeps the TUI connected to the server. It reads from conn until it
+// fails, then redials with exponential backoff and hands the new connection
+// to the TUI, which registers again. It gives up once the client is kicked.
+func run(p sender, conn net.Conn, dial func() (net.Conn, error), heartbeatInterval, idleTimeout time.Duration) {
+	for {
+		done := make(chan struct{})
+		if heartbeatInterval > 0 {
+			go heartbeat(conn, heartbeatInterval, done)
+		}
+		err := read(conn, p, idleTimeout)
+		close(done)
+		conn.Close()
+		p.Send(disconnectedMsg{err: err})
+		if errors.Is(err, errKicked) {
+			return
+		}
+
+		conn = reconnect(p, dial)
+		p.Send(reconnectedMsg{conn: conn})
+	}
+}
+
+func reconnect(p sender, dial func() (net.Conn, error)) net.Conn {
+	delay := minReconnectDelay
+	for attempt := 1; ; attempt++ {
+		p.Send(reconnectingMsg{attempt: attempt, delay: delay})
+		time.Sleep(delay)
+		conn, err := dial()
+		if err == nil {
+			return conn
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
 }
 
 func write(conn net.Conn, content []byte) error {
-	content = append(content, '\n')
-	if _, err := conn.Write(content); err != nil {
-		return err
+	return protocol.WriteFrame(conn, content)
+}
+
+// heartbeat pings the server every interval until done is closed.
+func heartbeat(conn net.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			write(conn, wrapAction(types.ActionTypePing, nil))
+		case <-done:
+			return
+		}
 	}
-	return nil
 }
 
-func read(conn net.Conn, p *tea.Program) {
-	scanner := bufio.NewScanner(conn)
-	for scanner.Scan() {
-		action := types.Action{}
-		action.UnmarshalMsg(scanner.Bytes())
+// read forwards the actions received from the server to the TUI until the
+// connection fails, nothing, not even a ping, arrives for idleTimeout or the
+// client is kicked.
+func read(conn net.Conn, p sender, idleTimeout time.Duration) error {
+	reader := protocol.NewReader(conn)
+	for {
+		if idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+		action, err := reader.ReadAction()
+		if err != nil {
+			return err
+		}
 		switch types.ActionType(action.Type) {
+		case types.ActionTypePing:
+			write(conn, wrapAction(types.ActionTypePong, nil))
+		case types.ActionTypeSession:
+			session := types.Session{}
+			session.UnmarshalMsg(action.Data)
+			p.Send(session)
 		case types.ActionTypeMessage:
 			msg := types.Message{}
 			msg.UnmarshalMsg(action.Data)
 			p.Send(msg)
+		case types.ActionTypeEdit, types.ActionTypeDelete, types.ActionTypeReact:
+			msg := types.Message{}
+			msg.UnmarshalMsg(action.Data)
+			p.Send(updatedMsg(msg))
+		case types.ActionTypeTyping:
+			typing := types.Typing{}
+			typing.UnmarshalMsg(action.Data)
+			p.Send(typing)
 		case types.ActionTypeGetUsers:
-			users := types.Users{}
-			users.UnmarshalMsg(action.Data)
-			p.Send(users)
+			room := types.Room{}
+			room.UnmarshalMsg(action.Data)
+			p.Send(room)
+		case types.ActionTypeLeaveRoom:
+			room := types.Room{}
+			room.UnmarshalMsg(action.Data)
+			p.Send(leftRoomMsg(room.Name))
+		case types.ActionTypeListRooms:
+			rooms := types.Rooms{}
+			rooms.UnmarshalMsg(action.Data)
+			p.Send(rooms)
+		case types.ActionTypePresence:
+			presence := types.Presence{}
+			presence.UnmarshalMsg(action.Data)
+			p.Send(presence)
+		case types.ActionTypeHistory:
+			history := types.History{}
+			history.UnmarshalMsg(action.Data)
+			p.Send(history)
+		case types.ActionTypeDirect:
+			msg := types.DirectMessage{}
+			msg.UnmarshalMsg(action.Data)
+			p.Send(msg)
+		case types.ActionTypeAck:
+			ack := types.Ack{}
+			ack.UnmarshalMsg(action.Data)
+			p.Send(ack)
+		case types.ActionTypeError:
+			msg := types.ErrorMessage{}
+			msg.UnmarshalMsg(action.Data)
+			if msg.Code == types.ErrorCodeKicked {
+				return fmt.Errorf("%w: %s", errKicked, msg.Value)
+			}
+			p.Send(errMsg(msg))
 		}
 	}
 }
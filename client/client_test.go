@@ -0,0 +1,69 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// recorder collects the messages sent to the TUI.
+type recorder chan tea.Msg
+
+func (r recorder) Send(msg tea.Msg) {
+	r <- msg
+}
+
+func TestKickedClientDoesNotReconnect(t *testing.T) {
+	conn, server := net.Pipe()
+	t.Cleanup(func() { server.Close() })
+	dialed := make(chan struct{}, 1)
+	dial := func() (net.Conn, error) {
+		dialed <- struct{}{}
+		return nil, errors.New("unexpected dial")
+	}
+
+	messages := recorder(make(chan tea.Msg, 10))
+	stopped := make(chan struct{})
+	go func() {
+		run(messages, conn, dial, 0, 0)
+		close(stopped)
+	}()
+
+	errB, _ := (&types.ErrorMessage{Code: types.ErrorCodeKicked, Value: "kicked by an operator"}).MarshalMsg(nil)
+	if err := protocol.WriteAction(server, types.ActionTypeError, errB); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	case <-dialed:
+		t.Fatal("expected a kicked client not to reconnect")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected run to stop once kicked")
+	}
+	disconnected, ok := (<-messages).(disconnectedMsg)
+	if !ok || !errors.Is(disconnected.err, errKicked) {
+		t.Errorf("expected the TUI to be told the client was kicked, got %+v", disconnected)
+	}
+}
+
+func TestRegisterErrorAfterReconnect(t *testing.T) {
+	m := initialModel(nil)
+	m.registered = true
+	m.rooms[types.DefaultRoom] = types.Users{{Username: "alice"}}
+	m.rooms["dev"] = types.Users{{Username: "alice"}}
+	m.authID = "7"
+
+	updated, _ := m.Update(errMsg(types.ErrorMessage{Code: types.ErrorCodeNotRoomMember, Value: "not a member", ID: "8"}))
+	if m = updated.(model); !m.registered {
+		t.Fatal("expected an error of another action to keep the chat view")
+	}
+	updated, _ = m.Update(errMsg(types.ErrorMessage{Code: types.ErrorCodeUsernameTaken, Value: "username alice is already taken", ID: "7"}))
+	if m = updated.(model); m.registered || len(m.rooms) != 0 {
+		t.Errorf("expected the register view without rooms, got registered %v and rooms %v", m.registered, m.rooms)
+	}
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// command is a slash command typed in the message input. run receives the
+// text following the command name.
+type command struct {
+	name  string
+	usage string
+	help  string
+	// online commands talk to the server and are refused while
+	// disconnected.
+	online bool
+	run    func(m *model, args string) tea.Cmd
+}
+
+// commands is the registry of slash commands, sorted by name.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "clear", help: "clear the messages of the active pane", run: clearCommand},
+		{name: "delete", help: "delete the selected message or your last one", online: true, run: deleteCommand},
+		{name: "edit", usage: "<text>", help: "edit the selected message or your last one", online: true, run: editCommand},
+		{name: "help", help: "list the commands", run: helpCommand},
+		{name: "join", usage: "<room>", help: "join a room", online: true, run: joinCommand},
+		{name: "me", usage: "<action>", help: "describe what you are doing", online: true, run: meCommand},
+		{name: "msg", usage: "<user> <text>", help: "send a direct message", online: true, run: msgCommand},
+		{name: "nick", usage: "<username>", help: "change your username", online: true, run: nickCommand},
+		{name: "part", usage: "[room]", help: "leave a room, the active one by default", online: true, run: partCommand},
+		{name: "quit", help: "exit the chat", run: quitCommand},
+		{name: "reply", usage: "<text>", help: "reply to the selected message", online: true, run: replyCommand},
+		{name: "rooms", help: "list the rooms", online: true, run: roomsCommand},
+		{name: "who", help: "list the users in the active room", run: whoCommand},
+	}
+}
+
+// isCommand reports whether input is a command. Inputs starting with "//" are
+// messages starting with a single slash.
+func isCommand(input string) bool {
+	return strings.HasPrefix(input, "/") && !strings.HasPrefix(input, "//")
+}
+
+// parseCommand splits input into the command name, without its slash, and its
+// arguments.
+func parseCommand(input string) (name, args string) {
+	name, args, _ = strings.Cut(strings.TrimSpace(strings.TrimPrefix(input, "/")), " ")
+	return name, strings.TrimSpace(args)
+}
+
+func findCommand(name string) (command, bool) {
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+	if i < 0 {
+		return command{}, false
+	}
+	return commands[i], true
+}
+
+// runCommand runs the command typed in input. Errors are only shown locally.
+func (m *model) runCommand(input string) tea.Cmd {
+	name, args := parseCommand(input)
+	c, ok := findCommand(name)
+	if !ok {
+		m.notice("unknown command /" + name + ", try /help")
+		return nil
+	}
+	if c.online && !m.connected {
+		m.notice("not connected, /" + name + " not sent")
+		return nil
+	}
+	return c.run(m, args)
+}
+
+func (m *model) notice(text string) {
+	m.appendMessage(m.pane, systemStyle.Render(text))
+}
+
+func (m *model) usage(name string) {
+	c, _ := findCommand(name)
+	m.notice(strings.TrimSpace("usage: /" + c.name + " " + c.usage))
+}
+
+func clearCommand(m *model, _ string) tea.Cmd {
+	delete(m.messages, m.pane)
+	m.selected = 0
+	m.refreshViewport()
+	return nil
+}
+
+func deleteCommand(m *model, _ string) tea.Cmd {
+	if id, ok := m.targetMessage(); ok {
+		deleteMessage(*m.conn, id)
+	}
+	return nil
+}
+
+func editCommand(m *model, args string) tea.Cmd {
+	if args == "" {
+		m.usage("edit")
+		return nil
+	}
+	if id, ok := m.targetMessage(); ok {
+		editMessage(*m.conn, id, args)
+	}
+	return nil
+}
+
+func helpCommand(m *model, _ string) tea.Cmd {
+	for _, c := range commands {
+		m.notice(fmt.Sprintf("%-22s %s", strings.TrimSpace("/"+c.name+" "+c.usage), c.help))
+	}
+	m.notice("shift+up/down select a message, ctrl+r reacts to it, ctrl+t opens its thread")
+	return nil
+}
+
+func joinCommand(m *model, args string) tea.Cmd {
+	if args == "" {
+		m.usage("join")
+		return nil
+	}
+	name := strings.TrimPrefix(args, "#")
+	joinRoom(*m.conn, name)
+	m.pane = roomPane(name)
+	m.selected = 0
+	m.thread = 0
+	m.refreshViewport()
+	return nil
+}
+
+func meCommand(m *model, args string) tea.Cmd {
+	if args == "" {
+		m.usage("me")
+		return nil
+	}
+	value := "/me " + args
+	if peer, ok := strings.CutPrefix(m.pane, "@"); ok {
+		nonce := m.appendOwn(m.pane, types.Message{Value: value})
+		sendDirect(*m.conn, peer, nonce, value)
+		return nil
+	}
+	m.sendRoomMessage(value, m.thread)
+	return nil
+}
+
+func msgCommand(m *model, args string) tea.Cmd {
+	peer, value, _ := strings.Cut(args, " ")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		m.usage("msg")
+		return nil
+	}
+	m.directs[peer] = true
+	m.pane = directPane(peer)
+	m.selected = 0
+	m.thread = 0
+	nonce := m.appendOwn(m.pane, types.Message{Value: value})
+	sendDirect(*m.conn, peer, nonce, value)
+	return nil
+}
+
+func nickCommand(m *model, args string) tea.Cmd {
+	if args == "" || strings.Contains(args, " ") {
+		m.usage("nick")
+		return nil
+	}
+	rename(*m.conn, args)
+	return nil
+}
+
+func partCommand(m *model, args string) tea.Cmd {
+	name := m.currentRoom()
+	if args != "" {
+		name = strings.TrimPrefix(args, "#")
+	}
+	if name == "" {
+		m.usage("part")
+		return nil
+	}
+	leaveRoom(*m.conn, name)
+	return nil
+}
+
+func quitCommand(*model, string) tea.Cmd {
+	return tea.Quit
+}
+
+func replyCommand(m *model, args string) tea.Cmd {
+	if args == "" || m.selected == 0 {
+		m.notice("usage: select a message with shift+up, then /reply <text>")
+		return nil
+	}
+	m.sendRoomMessage(args, m.selected)
+	return nil
+}
+
+func roomsCommand(m *model, _ string) tea.Cmd {
+	listRooms(*m.conn)
+	return nil
+}
+
+func whoCommand(m *model, _ string) tea.Cmd {
+	room := m.currentRoom()
+	if room == "" {
+		m.notice("/who only works in rooms")
+		return nil
+	}
+	users := []string{}
+	for _, u := range m.rooms[room] {
+		users = append(users, u.Username)
+	}
+	slices.Sort(users)
+	m.notice(fmt.Sprintf("%d users in #%s: %s", len(users), room, strings.Join(users, ", ")))
+	return nil
+}
+
+// complete completes the word before the end of the message input: command
+// names at the start of a command, usernames anywhere else. A unique match is
+// completed in full, otherwise the common prefix of the matches is completed
+// and the matches are listed.
+func (m *model) complete() {
+	input := m.messageInput.Value()
+	start := strings.LastIndexAny(input, " ") + 1
+	word := input[start:]
+	candidates := []string{}
+	if start == 0 && isCommand(word) {
+		for _, c := range commands {
+			candidates = append(candidates, "/"+c.name)
+		}
+	} else {
+		candidates = m.knownUsernames()
+	}
+	matches := completions(word, candidates)
+	switch len(matches) {
+	case 0:
+		return
+	case 1:
+		m.messageInput.SetValue(input[:start] + matches[0] + " ")
+	default:
+		// The typed word is kept as is, as matches may differ in case.
+		m.messageInput.SetValue(input[:start] + word + commonPrefix(matches)[len(word):])
+		m.notice(strings.Join(matches, "  "))
+	}
+	m.messageInput.CursorEnd()
+}
+
+// knownUsernames returns the usernames of the active room and of the direct
+// conversations.
+func (m model) knownUsernames() []string {
+	usernames := []string{}
+	for _, u := range m.rooms[m.currentRoom()] {
+		usernames = append(usernames, u.Username)
+	}
+	for username := range m.directs {
+		usernames = append(usernames, username)
+	}
+	slices.Sort(usernames)
+	return slices.Compact(usernames)
+}
+
+// completions returns the candidates starting with word, ignoring case.
+func completions(word string, candidates []string) []string {
+	matches := []string{}
+	for _, candidate := range candidates {
+		if len(candidate) >= len(word) && strings.EqualFold(candidate[:len(word)], word) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// commonPrefix returns the longest prefix of the first word shared by the
+// others, ignoring case.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for len(prefix) > len(word) || !strings.EqualFold(word[:len(prefix)], prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
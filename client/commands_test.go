@@ -0,0 +1,61 @@
+package client
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func TestParseCommand(t *testing.T) {
+	for input, expected := range map[string][2]string{
+		"/join #go":            {"join", "#go"},
+		"/msg  bob  hi there ": {"msg", "bob  hi there"},
+		"/quit":                {"quit", ""},
+	} {
+		name, args := parseCommand(input)
+		if name != expected[0] || args != expected[1] {
+			t.Errorf("expected %q to parse as %q, got %q %q", input, expected, name, args)
+		}
+	}
+	if isCommand("//not a command") || !isCommand("/help") {
+		t.Error("expected only a single leading slash to start a command")
+	}
+}
+
+func TestCommandsSorted(t *testing.T) {
+	if !slices.IsSortedFunc(commands, func(a, b command) int { return strings.Compare(a.name, b.name) }) {
+		t.Error("expected the commands to be sorted by name")
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	candidates := []string{"alex", "Alice", "bob"}
+	if matches := completions("AL", candidates); !slices.Equal(matches, []string{"alex", "Alice"}) {
+		t.Errorf("expected alex and Alice, got %v", matches)
+	}
+	if prefix := commonPrefix([]string{"/part", "/ping"}); prefix != "/p" {
+		t.Errorf("expected /p, got %q", prefix)
+	}
+	if prefix := commonPrefix([]string{"Alice", "alex"}); prefix != "Al" {
+		t.Errorf("expected Al, got %q", prefix)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	m := initialModel(nil)
+	m.rooms[types.DefaultRoom] = types.Users{{Username: "alex"}, {Username: "Alice"}, {Username: "bob"}}
+	for input, expected := range map[string]string{
+		"hi al":  "hi al",
+		"hi ali": "hi Alice ",
+		"hi B":   "hi bob ",
+		"/jo":    "/join ",
+	} {
+		m.messageInput.SetValue(input)
+		m.complete()
+		if value := m.messageInput.Value(); value != expected {
+			t.Errorf("expected %q to complete to %q, got %q", input, expected, value)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package client
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -16,10 +19,70 @@ import (
 
 type errMsg error
 
+// disconnectedMsg is sent when the connection to the server is lost.
+type disconnectedMsg struct {
+	err error
+}
+
+// reconnectingMsg is sent before every attempt to reconnect to the server.
+type reconnectingMsg struct {
+	attempt int
+	delay   time.Duration
+}
+
+// reconnectedMsg carries the new connection to the server.
+type reconnectedMsg struct {
+	conn net.Conn
+}
+
+const (
+	// defaultReaction is used when reacting with an empty message input.
+	defaultReaction = "👍"
+	// quoteLength is the number of characters of a message quoted by its
+	// replies.
+	quoteLength = 40
+	// typingThrottle is the interval between the typing notifications sent
+	// while the message input is not empty.
+	typingThrottle = 3 * time.Second
+	// typingTimeout is how long a user is shown as typing after their last
+	// notification.
+	typingTimeout = 5 * time.Second
+)
+
+// leftRoomMsg is sent when the server confirms that we left a room.
+type leftRoomMsg string
+
+// typingExpiredMsg is sent when a typing notification may have expired.
+type typingExpiredMsg struct{}
+
+// updatedMsg carries a message that was edited, deleted or reacted to.
+type updatedMsg types.Message
+
+type deliveryStatus int
+
+const (
+	deliveryPending deliveryStatus = iota
+	deliverySent
+	deliveryFailed
+)
+
+// line is an entry of a pane. Chat messages are kept structured so they can be
+// rendered again when they are acknowledged, edited or deleted, while notices
+// are stored as rendered text.
+type line struct {
+	text    string
+	message types.Message
+	// nonce and status are only set for messages we sent.
+	nonce  string
+	status deliveryStatus
+}
+
 var (
 	blurredStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
 	senderStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
 	receiverStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("4"))
+	systemStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	activeStyle   = lipgloss.NewStyle().Bold(true)
 	titleStyle    = func() lipgloss.Style {
 		b := lipgloss.RoundedBorder()
 		b.Right = "├"
@@ -30,15 +93,36 @@ var (
 		return lipgloss.NewStyle().BorderStyle(b).Padding(0, 1)
 	}()
 	helpStyle = blurredStyle.Copy()
+	timeStyle = blurredStyle.Copy()
 )
 
 type model struct {
 	viewport      viewport.Model
-	messages      []string
+	messages      map[string][]line
 	users         map[string]types.User
 	usersLength   int
+	pane          string
+	rooms         map[string]types.Users
+	directs       map[string]bool
+	history       map[string]types.History
+	loading       map[string]bool
+	pending       map[string]string
+	selected      uint64
+	thread        uint64
+	typing        map[string]map[string]time.Time
+	typedRoom     string
+	typedAt       time.Time
 	registered    bool
+	connected     bool
+	reconnecting  int
+	retryDelay    time.Duration
+	username      string
+	userID        string
+	token         string
+	authID        string
 	usernameInput textinput.Model
+	passwordInput textinput.Model
+	password      string
 	messageInput  textinput.Model
 	viewportReady bool
 	height        int
@@ -64,13 +148,28 @@ Type a message and press Enter to send.`)
 	ti.CharLimit = 20
 	ti.Width = 20
 
+	pi := textinput.New()
+	pi.Placeholder = "Password (optional)"
+	pi.EchoMode = textinput.EchoPassword
+	pi.EchoCharacter = '•'
+	pi.Width = 20
+
 	return model{
 		messageInput:  mi,
-		messages:      []string{},
+		messages:      map[string][]line{},
 		users:         map[string]types.User{},
 		usersLength:   0,
+		pane:          roomPane(types.DefaultRoom),
+		rooms:         map[string]types.Users{},
+		directs:       map[string]bool{},
+		history:       map[string]types.History{},
+		loading:       map[string]bool{},
+		pending:       map[string]string{},
+		typing:        map[string]map[string]time.Time{},
 		registered:    false,
+		connected:     true,
 		usernameInput: ti,
+		passwordInput: pi,
 		viewport:      vp,
 		viewportReady: false,
 		height:        10,
@@ -96,11 +195,36 @@ func updateRegister(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "ctrl+c", "esc":
 			return m, tea.Quit
+		case "tab", "shift+tab", "up", "down":
+			if m.usernameInput.Focused() {
+				m.usernameInput.Blur()
+				return m, m.passwordInput.Focus()
+			}
+			m.passwordInput.Blur()
+			return m, m.usernameInput.Focus()
 		case "enter":
-			register(*m.conn, m.usernameInput.Value())
+			m.username = m.usernameInput.Value()
+			m.password = m.passwordInput.Value()
+			m.passwordInput.Reset()
+			m.authenticate()
 			m.registered = true
+			m.err = nil
 			return m, nil
 		}
+	case disconnectedMsg:
+		m.connected = false
+		m.err = msg.err
+		return m, nil
+	case reconnectingMsg:
+		m.reconnecting = msg.attempt
+		m.retryDelay = msg.delay
+		return m, nil
+	case reconnectedMsg:
+		*m.conn = msg.conn
+		m.connected = true
+		m.reconnecting = 0
+		m.err = nil
+		return m, nil
 
 	case tea.WindowSizeMsg:
 		headerHeight := lipgloss.Height(m.headerView())
@@ -120,10 +244,25 @@ func updateRegister(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	var cmd tea.Cmd
-	m.usernameInput, cmd = m.usernameInput.Update(msg)
+	var (
+		uiCmd tea.Cmd
+		piCmd tea.Cmd
+	)
+	m.usernameInput, uiCmd = m.usernameInput.Update(msg)
+	m.passwordInput, piCmd = m.passwordInput.Update(msg)
+
+	return m, tea.Batch(uiCmd, piCmd)
+}
 
-	return m, cmd
+// authenticate logs in when a password was given and registers otherwise,
+// resuming the current session if there is one. The ID of the action is kept
+// in authID, so an error answering it sends the user back to the register view.
+func (m *model) authenticate() {
+	if m.password != "" {
+		m.authID = login(*m.conn, m.username, m.password, m.token)
+		return
+	}
+	m.authID = register(*m.conn, m.username, m.token)
 }
 
 func updateChat(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -132,45 +271,670 @@ func updateChat(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 		vpCmd tea.Cmd
 	)
 
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "alt+up":
+			m.switchPane(-1)
+			return m, nil
+		case "alt+down":
+			m.switchPane(1)
+			return m, nil
+		case "shift+up":
+			m.selectMessage(-1)
+			return m, nil
+		case "shift+down":
+			m.selectMessage(1)
+			return m, nil
+		case "ctrl+r":
+			m.react()
+			return m, nil
+		case "ctrl+t":
+			m.toggleThread()
+			return m, nil
+		case "tab":
+			m.complete()
+			return m, nil
+		}
+	}
+
 	m.messageInput, tiCmd = m.messageInput.Update(msg)
 	m.viewport, vpCmd = m.viewport.Update(msg)
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.Type != tea.KeyEnter {
+		m.notifyTyping()
+	}
 
 	switch msg := msg.(type) {
+	case tea.MouseMsg:
+		if msg.Type == tea.MouseWheelUp {
+			m.loadHistory()
+		}
 	case tea.KeyMsg:
 		switch msg.Type {
+		case tea.KeyUp, tea.KeyPgUp:
+			m.loadHistory()
 		case tea.KeyCtrlC, tea.KeyEsc:
 			fmt.Println(m.messageInput.Value())
 			return m, tea.Quit
 		case tea.KeyEnter:
-			m.messages = append(m.messages, senderStyle.Render("[you]: ")+m.messageInput.Value())
-			m.viewport.SetContent(strings.Join(m.messages, "\n"))
-			sendMessage(*m.conn, m.messageInput.Value())
+			value := m.messageInput.Value()
+			if isCommand(value) {
+				m.messageInput.Reset()
+				return m, tea.Batch(tiCmd, vpCmd, m.runCommand(value))
+			}
+			value = strings.TrimPrefix(value, "/")
+			if !m.connected {
+				m.appendMessage(m.pane, systemStyle.Render("not connected, message not sent"))
+				return m, tea.Batch(tiCmd, vpCmd)
+			}
 			m.messageInput.Reset()
-			m.viewport.GotoBottom()
+			if peer, ok := strings.CutPrefix(m.pane, "@"); ok {
+				nonce := m.appendOwn(m.pane, types.Message{Value: value})
+				sendDirect(*m.conn, peer, nonce, value)
+			} else {
+				m.sendRoomMessage(value, m.thread)
+			}
 		}
-	case types.Users:
-		m.users = map[string]types.User{}
-		m.usersLength = len(msg)
-		for _, u := range msg {
+	case types.Room:
+		if _, ok := m.rooms[msg.Name]; !ok {
+			m.appendMessage(roomPane(msg.Name), systemStyle.Render("joined #"+msg.Name))
+		}
+		m.rooms[msg.Name] = msg.Users
+		for _, u := range msg.Users {
 			m.users[u.ID] = u
 		}
-		m.viewport.SetContent(strings.Join(m.messages, "\n"))
-		m.viewport.GotoBottom()
+		m.usersLength = len(m.rooms[m.currentRoom()])
+		m.refreshViewport()
+		return m, nil
+	case types.Rooms:
+		names := []string{}
+		for _, r := range msg {
+			names = append(names, fmt.Sprintf("#%s (%d)", r.Name, len(r.Users)))
+		}
+		m.appendMessage(m.pane, systemStyle.Render("rooms: "+strings.Join(names, ", ")))
+		return m, nil
+	case leftRoomMsg:
+		delete(m.rooms, string(msg))
+		delete(m.messages, roomPane(string(msg)))
+		delete(m.history, string(msg))
+		if m.pane == roomPane(string(msg)) {
+			m.pane = roomPane(types.DefaultRoom)
+			m.switchPane(0)
+		}
 		return m, nil
 	case types.Message:
-		user := m.users[msg.UserID]
-		m.messages = append(m.messages, receiverStyle.Render(fmt.Sprintf("[%s]: ", user.Username))+msg.Value)
-		m.viewport.SetContent(strings.Join(m.messages, "\n"))
-		m.viewport.GotoBottom()
+		room := msg.Room
+		if room == "" {
+			room = types.DefaultRoom
+		}
+		delete(m.typing[room], msg.Username)
+		m.appendLine(roomPane(room), line{message: msg})
+		return m, nil
+	case types.Typing:
+		if m.typing[msg.Room] == nil {
+			m.typing[msg.Room] = map[string]time.Time{}
+		}
+		m.typing[msg.Room][msg.Username] = time.Now().Add(typingTimeout)
+		return m, tea.Tick(typingTimeout, func(time.Time) tea.Msg { return typingExpiredMsg{} })
+	case typingExpiredMsg:
+		now := time.Now()
+		for _, users := range m.typing {
+			maps.DeleteFunc(users, func(_ string, until time.Time) bool { return !until.After(now) })
+		}
+		return m, nil
+	case updatedMsg:
+		m.updateMessage(types.Message(msg))
+		return m, nil
+	case types.History:
+		m.prependHistory(msg)
+		return m, nil
+	case types.DirectMessage:
+		m.users[msg.UserID] = types.User{ID: msg.UserID, Username: msg.Username}
+		m.directs[msg.Username] = true
+		m.appendLine(directPane(msg.Username), line{message: types.Message{
+			UserID:    msg.UserID,
+			Username:  msg.Username,
+			Value:     msg.Value,
+			Timestamp: msg.Timestamp,
+		}})
+		return m, nil
+	case types.Ack:
+		m.deliver(msg.Nonce, deliverySent, msg)
+		return m, nil
+	case types.Presence:
+		m.updatePresence(msg)
+		return m, nil
+	case disconnectedMsg:
+		m.connected = false
+		for nonce := range m.pending {
+			m.deliver(nonce, deliveryFailed, types.Ack{})
+		}
+		m.appendMessage(m.pane, systemStyle.Render("disconnected from server: "+msg.err.Error()))
+		return m, nil
+	case reconnectingMsg:
+		m.reconnecting = msg.attempt
+		m.retryDelay = msg.delay
+		return m, nil
+	case reconnectedMsg:
+		*m.conn = msg.conn
+		m.connected = true
+		m.reconnecting = 0
+		m.appendMessage(m.pane, systemStyle.Render("reconnected to server"))
+		m.authenticate()
+		return m, nil
+	case types.Session:
+		m.resumeSession(msg)
 		return m, nil
 	case errMsg:
 		m.err = msg
+		var protocolErr types.ErrorMessage
+		if errors.As(msg, &protocolErr) && protocolErr.ID != "" && protocolErr.ID == m.authID {
+			// The registration failed, after reconnecting too, so the
+			// connection is not registered and the rooms were left.
+			m.registered = false
+			m.rooms = map[string]types.Users{}
+			m.authID = ""
+			m.err = errors.New(protocolErr.Value)
+			return m, nil
+		}
+		if _, ok := m.pending[protocolErr.ID]; ok {
+			m.deliver(protocolErr.ID, deliveryFailed, types.Ack{})
+		}
+		m.appendMessage(m.pane, systemStyle.Render("error: "+msg.Error()))
 		return m, nil
 	}
 
 	return m, tea.Batch(tiCmd, vpCmd)
 }
 
+func (m model) renderLine(l line) string {
+	if l.text != "" {
+		return l.text
+	}
+	msg := l.message
+	var b strings.Builder
+	if msg.ReplyTo != 0 {
+		b.WriteString(m.renderQuote(msg) + "\n")
+	}
+	if msg.ID != 0 && msg.ID == m.selected {
+		b.WriteString(activeStyle.Render("» "))
+	}
+	if l.nonce != "" && l.status != deliverySent {
+		b.WriteString(timeStyle.Render("--:-- "))
+	} else {
+		b.WriteString(renderTime(msg.Timestamp))
+	}
+	style := receiverStyle
+	if msg.UserID == m.userID {
+		style = senderStyle
+	}
+	emote, isEmote := strings.CutPrefix(msg.Value, "/me ")
+	if isEmote && !msg.Deleted {
+		name := m.displayName(msg)
+		if msg.UserID == m.userID {
+			name = m.username
+		}
+		b.WriteString(style.Render("* " + name + " "))
+	} else {
+		b.WriteString(style.Render(fmt.Sprintf("[%s]: ", m.displayName(msg))))
+	}
+	if msg.Deleted {
+		b.WriteString(timeStyle.Render("message deleted"))
+		return b.String()
+	}
+	if isEmote {
+		b.WriteString(emote)
+	} else {
+		b.WriteString(msg.Value)
+	}
+	if msg.Edited {
+		b.WriteString(timeStyle.Render(" (edited)"))
+	}
+	if l.nonce != "" {
+		switch l.status {
+		case deliveryPending:
+			b.WriteString(timeStyle.Render(" (sending…)"))
+		case deliveryFailed:
+			b.WriteString(systemStyle.Render(" (failed)"))
+		case deliverySent:
+			b.WriteString(timeStyle.Render(" ✓"))
+		}
+	}
+	if len(msg.Reactions) > 0 {
+		reactions := []string{}
+		for _, r := range msg.Reactions {
+			reaction := fmt.Sprintf("%s %d", r.Emoji, r.Count)
+			if slices.Contains(r.UserIDs, m.userID) {
+				reactions = append(reactions, senderStyle.Render(reaction))
+			} else {
+				reactions = append(reactions, timeStyle.Render(reaction))
+			}
+		}
+		b.WriteString("\n      " + strings.Join(reactions, "  "))
+	}
+	return b.String()
+}
+
+// notifyTyping tells the active room that we are typing, at most once every
+// typingThrottle.
+func (m *model) notifyTyping() {
+	room := m.currentRoom()
+	value := m.messageInput.Value()
+	if room == "" || !m.connected || value == "" || isCommand(value) {
+		return
+	}
+	if room == m.typedRoom && time.Since(m.typedAt) < typingThrottle {
+		return
+	}
+	m.typedRoom = room
+	m.typedAt = time.Now()
+	sendTyping(*m.conn, room)
+}
+
+// renderQuote renders the start of the message msg replies to.
+func (m model) renderQuote(msg types.Message) string {
+	parent, ok := m.findMessage(msg.Room, msg.ReplyTo)
+	if !ok {
+		return timeStyle.Render("      ↪ reply to an earlier message")
+	}
+	value := parent.Value
+	if parent.Deleted {
+		value = "message deleted"
+	}
+	if runes := []rune(value); len(runes) > quoteLength {
+		value = string(runes[:quoteLength]) + "…"
+	}
+	return timeStyle.Render(fmt.Sprintf("      ↪ %s: %s", m.displayName(parent), value))
+}
+
+// displayName returns the current username of the author of msg.
+func (m model) displayName(msg types.Message) string {
+	if msg.UserID == m.userID {
+		return "you"
+	}
+	if user, ok := m.users[msg.UserID]; ok {
+		return user.Username
+	}
+	return msg.Username
+}
+
+// findMessage looks up the message id among the loaded messages of room.
+func (m model) findMessage(room string, id uint64) (types.Message, bool) {
+	for _, l := range m.messages[roomPane(room)] {
+		if l.text == "" && l.message.ID == id {
+			return l.message, true
+		}
+	}
+	return types.Message{}, false
+}
+
+// threadOf returns the ID of the first message of the thread msg is part of,
+// as far as the loaded messages go back.
+func (m model) threadOf(msg types.Message) uint64 {
+	root := msg.ID
+	for parent := msg.ReplyTo; parent != 0; {
+		root = parent
+		p, ok := m.findMessage(msg.Room, parent)
+		if !ok {
+			break
+		}
+		parent = p.ReplyTo
+	}
+	return root
+}
+
+// visibleLines returns the lines of the active pane, only keeping the messages
+// of the open thread if there is one.
+func (m model) visibleLines() []line {
+	if m.thread == 0 {
+		return m.messages[m.pane]
+	}
+	lines := []line{}
+	for _, l := range m.messages[m.pane] {
+		if l.text == "" && m.threadOf(l.message) == m.thread {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// toggleThread opens the thread of the selected message, or closes the open
+// thread. Messages sent while a thread is open reply to it.
+func (m *model) toggleThread() {
+	if m.thread != 0 {
+		m.thread = 0
+		m.refreshViewport()
+		return
+	}
+	msg, ok := m.findMessage(m.currentRoom(), m.selected)
+	if !ok {
+		m.appendMessage(m.pane, systemStyle.Render("select a message with shift+up to open its thread"))
+		return
+	}
+	m.thread = m.threadOf(msg)
+	m.refreshViewport()
+}
+
+// renderTime renders a server timestamp in Unix milliseconds.
+func renderTime(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return timeStyle.Render(time.UnixMilli(timestamp).Format("15:04") + " ")
+}
+
+// appendOwn shows msg as pending in pane and returns the nonce to send it with.
+func (m *model) appendOwn(pane string, msg types.Message) string {
+	nonce := nextActionID()
+	m.pending[nonce] = pane
+	msg.UserID = m.userID
+	m.appendLine(pane, line{message: msg, nonce: nonce, status: deliveryPending})
+	return nonce
+}
+
+// sendRoomMessage sends value to the active room, as a reply to the message
+// replyTo unless it is zero.
+func (m *model) sendRoomMessage(value string, replyTo uint64) {
+	msg := types.Message{Room: m.currentRoom(), Value: value, ReplyTo: replyTo}
+	msg.Nonce = m.appendOwn(m.pane, msg)
+	sendMessage(*m.conn, msg)
+}
+
+// deliver updates the status of the pending message nonce, taking the ID and
+// timestamp assigned by the server from ack.
+func (m *model) deliver(nonce string, status deliveryStatus, ack types.Ack) {
+	pane, ok := m.pending[nonce]
+	if !ok {
+		return
+	}
+	delete(m.pending, nonce)
+	lines := m.messages[pane]
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].nonce == nonce {
+			lines[i].status = status
+			lines[i].message.ID = ack.ID
+			lines[i].message.Timestamp = ack.Timestamp
+			break
+		}
+	}
+	if pane == m.pane {
+		m.renderViewport()
+	}
+}
+
+// updateMessage replaces a message that was edited or deleted.
+func (m *model) updateMessage(msg types.Message) {
+	pane := roomPane(msg.Room)
+	lines := m.messages[pane]
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].text == "" && lines[i].message.ID == msg.ID {
+			lines[i].message = msg
+			break
+		}
+	}
+	if pane == m.pane {
+		m.renderViewport()
+	}
+}
+
+// targetMessage returns the ID of the selected message or, when none is
+// selected, of the last message we sent to the active room that the server
+// acknowledged.
+func (m *model) targetMessage() (uint64, bool) {
+	if m.currentRoom() == "" {
+		m.appendMessage(m.pane, systemStyle.Render("direct messages cannot be changed"))
+		return 0, false
+	}
+	if m.selected != 0 {
+		return m.selected, true
+	}
+	lines := m.messages[m.pane]
+	for i := len(lines) - 1; i >= 0; i-- {
+		msg := lines[i].message
+		if lines[i].text == "" && msg.UserID == m.userID && msg.ID != 0 && !msg.Deleted {
+			return msg.ID, true
+		}
+	}
+	m.appendMessage(m.pane, systemStyle.Render("no message of yours to change"))
+	return 0, false
+}
+
+// selectMessage moves the selection by offset messages of the active room.
+// Moving up without a selection selects the last message and moving down past
+// the last one clears the selection.
+func (m *model) selectMessage(offset int) {
+	lines := m.visibleLines()
+	i := len(lines)
+	if m.selected != 0 {
+		if j := slices.IndexFunc(lines, func(l line) bool { return l.text == "" && l.message.ID == m.selected }); j >= 0 {
+			i = j
+		}
+	}
+	for i += offset; i >= 0 && i < len(lines); i += offset {
+		if l := lines[i]; l.text == "" && l.message.ID != 0 && !l.message.Deleted {
+			m.selected = l.message.ID
+			m.renderViewport()
+			return
+		}
+	}
+	if offset > 0 {
+		m.selected = 0
+		m.renderViewport()
+	}
+}
+
+// react toggles a reaction on the selected message. The reaction is taken from
+// the message input, defaulting to a thumbs up.
+func (m *model) react() {
+	if m.selected == 0 {
+		m.appendMessage(m.pane, systemStyle.Render("select a message with shift+up to react to it"))
+		return
+	}
+	emoji := strings.TrimSpace(m.messageInput.Value())
+	if emoji == "" {
+		emoji = defaultReaction
+	}
+	m.messageInput.Reset()
+	react(*m.conn, m.selected, emoji)
+}
+
+// loadHistory requests the previous page of the active room once the viewport
+// is scrolled to the top.
+func (m *model) loadHistory() {
+	room := m.currentRoom()
+	if room == "" || !m.viewport.AtTop() || m.loading[room] {
+		return
+	}
+	if history := m.history[room]; !history.More {
+		return
+	}
+	m.loading[room] = true
+	requestHistory(*m.conn, room, m.history[room].Before)
+}
+
+// prependHistory adds a page of older messages above the ones already shown,
+// keeping the viewport at the same position.
+func (m *model) prependHistory(history types.History) {
+	m.loading[history.Room] = false
+	_, loaded := m.history[history.Room]
+	m.history[history.Room] = history
+	lines := []line{}
+	rendered := []string{}
+	for _, msg := range history.Messages {
+		lines = append(lines, line{message: msg})
+		rendered = append(rendered, m.renderLine(line{message: msg}))
+	}
+	pane := roomPane(history.Room)
+	m.messages[pane] = append(lines, m.messages[pane]...)
+	if pane != m.pane {
+		return
+	}
+	if !loaded {
+		m.refreshViewport()
+		return
+	}
+	m.renderViewport()
+	if len(rendered) > 0 {
+		m.viewport.SetYOffset(lipgloss.Height(strings.Join(rendered, "\n")))
+	}
+}
+
+// resumeSession stores the session issued by the server. When a session could
+// not be resumed after reconnecting, the rooms are joined again from scratch.
+func (m *model) resumeSession(session types.Session) {
+	previous := m.userID
+	m.userID = session.UserID
+	m.token = session.Token
+	if previous == "" || session.Resumed {
+		return
+	}
+	m.appendMessage(m.pane, systemStyle.Render("session expired, joining rooms again"))
+	rooms := m.rooms
+	m.rooms = map[string]types.Users{}
+	for name := range rooms {
+		delete(m.messages, roomPane(name))
+		delete(m.history, name)
+		if name != types.DefaultRoom {
+			joinRoom(*m.conn, name)
+		}
+	}
+	m.refreshViewport()
+}
+
+// updatePresence keeps the user lists in sync with users joining, leaving and
+// renaming, and announces it in the affected rooms.
+func (m *model) updatePresence(presence types.Presence) {
+	user := presence.User
+	switch presence.Type {
+	case types.PresenceJoined:
+		m.users[user.ID] = user
+		if _, ok := m.rooms[presence.Room]; !ok {
+			return
+		}
+		if !slices.ContainsFunc(m.rooms[presence.Room], func(u types.User) bool { return u.ID == user.ID }) {
+			m.rooms[presence.Room] = append(m.rooms[presence.Room], user)
+		}
+		m.appendMessage(roomPane(presence.Room), systemStyle.Render(user.Username+" joined #"+presence.Room))
+	case types.PresenceLeft:
+		if _, ok := m.rooms[presence.Room]; !ok {
+			return
+		}
+		m.rooms[presence.Room] = slices.DeleteFunc(m.rooms[presence.Room], func(u types.User) bool { return u.ID == user.ID })
+		m.appendMessage(roomPane(presence.Room), systemStyle.Render(user.Username+" left #"+presence.Room))
+	case types.PresenceRenamed:
+		m.rename(presence.Previous, user)
+	}
+	m.usersLength = len(m.rooms[m.currentRoom()])
+}
+
+// rename updates every place showing the old username of user and announces
+// the change in the rooms shared with them.
+func (m *model) rename(old string, user types.User) {
+	m.users[user.ID] = user
+	if old == m.username {
+		m.username = user.Username
+	}
+	for name, users := range m.rooms {
+		i := slices.IndexFunc(users, func(u types.User) bool { return u.ID == user.ID })
+		if i < 0 {
+			continue
+		}
+		users[i] = user
+		m.appendMessage(roomPane(name), systemStyle.Render(old+" is now known as "+user.Username))
+	}
+	if m.directs[old] {
+		delete(m.directs, old)
+		m.directs[user.Username] = true
+		m.messages[directPane(user.Username)] = m.messages[directPane(old)]
+		delete(m.messages, directPane(old))
+		if m.pane == directPane(old) {
+			m.pane = directPane(user.Username)
+		}
+		for nonce, pane := range m.pending {
+			if pane == directPane(old) {
+				m.pending[nonce] = directPane(user.Username)
+			}
+		}
+	}
+}
+
+func roomPane(name string) string {
+	return "#" + name
+}
+
+func directPane(username string) string {
+	return "@" + username
+}
+
+// currentRoom returns the room of the active pane, or an empty string when a
+// direct conversation is active.
+func (m model) currentRoom() string {
+	if room, ok := strings.CutPrefix(m.pane, "#"); ok {
+		return room
+	}
+	return ""
+}
+
+// panes returns the joined rooms followed by the direct conversations, in the
+// order they are shown in the side panel.
+func (m model) panes() []string {
+	rooms := []string{}
+	for name := range m.rooms {
+		rooms = append(rooms, roomPane(name))
+	}
+	slices.Sort(rooms)
+	directs := []string{}
+	for username := range m.directs {
+		directs = append(directs, directPane(username))
+	}
+	slices.Sort(directs)
+	return append(rooms, directs...)
+}
+
+// switchPane moves the active pane by offset positions in the pane list.
+func (m *model) switchPane(offset int) {
+	panes := m.panes()
+	if len(panes) == 0 {
+		return
+	}
+	i := slices.Index(panes, m.pane)
+	if i < 0 {
+		i = 0
+	}
+	i = (i + offset + len(panes)) % len(panes)
+	m.pane = panes[i]
+	m.selected = 0
+	m.thread = 0
+	m.usersLength = len(m.rooms[m.currentRoom()])
+	m.refreshViewport()
+}
+
+// appendMessage appends a notice, already rendered, to pane.
+func (m *model) appendMessage(pane, text string) {
+	m.appendLine(pane, line{text: text})
+}
+
+func (m *model) appendLine(pane string, l line) {
+	m.messages[pane] = append(m.messages[pane], l)
+	if pane == m.pane {
+		m.refreshViewport()
+	}
+}
+
+func (m *model) refreshViewport() {
+	m.renderViewport()
+	m.viewport.GotoBottom()
+}
+
+// renderViewport renders the lines of the active pane without scrolling.
+func (m *model) renderViewport() {
+	lines := m.visibleLines()
+	rendered := make([]string, 0, len(lines))
+	for _, l := range lines {
+		rendered = append(rendered, m.renderLine(l))
+	}
+	m.viewport.SetContent(strings.Join(rendered, "\n"))
+}
+
 func (m model) View() string {
 	if m.registered {
 		return chatView(m)
@@ -192,8 +956,15 @@ func registerView(m model) string {
 
 	b.WriteString(m.usernameInput.View())
 	b.WriteRune('\n')
+	b.WriteString(m.passwordInput.View())
+	b.WriteRune('\n')
+	if m.err != nil {
+		b.WriteRune('\n')
+		b.WriteString(systemStyle.Render(m.err.Error()))
+		b.WriteRune('\n')
+	}
 	b.WriteRune('\n')
-	b.WriteString(helpStyle.Render("press enter to submit"))
+	b.WriteString(helpStyle.Render("press tab to switch fields, enter to submit"))
 	b.WriteRune('\n')
 	b.WriteString(helpStyle.Render("press esc o ctrl+c to exit"))
 
@@ -201,8 +972,25 @@ func registerView(m model) string {
 }
 
 func (m model) sideView() string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, m.panesView(), m.usersView())
+}
+
+func (m model) panesView() string {
+	panes := []string{}
+	for _, pane := range m.panes() {
+		if pane == m.pane {
+			panes = append(panes, activeStyle.Render("> "+pane))
+			continue
+		}
+		panes = append(panes, "  "+pane)
+	}
+	panesList := strings.Join(panes, "\n")
+	return sideStyle.MaxHeight(m.height).Height(m.height - len(panes)).Render(panesList)
+}
+
+func (m model) usersView() string {
 	users := []string{}
-	for _, v := range m.users {
+	for _, v := range m.rooms[m.currentRoom()] {
 		users = append(users, v.Username)
 	}
 	usersList := ""
@@ -212,7 +1000,11 @@ func (m model) sideView() string {
 }
 
 func (m model) headerView() string {
-	title := titleStyle.Render("TCP Chat")
+	name := m.pane
+	if m.thread != 0 {
+		name += " › thread"
+	}
+	title := titleStyle.Render("TCP Chat " + name)
 	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
 }
@@ -220,5 +1012,37 @@ func (m model) headerView() string {
 func (m model) footerView() string {
 	line := strings.Repeat("─", max(0, m.viewport.Width))
 	input := m.messageInput.View()
+	if m.reconnecting > 0 {
+		status := fmt.Sprintf("reconnecting… (attempt %d, waiting %s)", m.reconnecting, m.retryDelay)
+		return lipgloss.JoinVertical(lipgloss.Left, line, input, systemStyle.Render(status))
+	}
+	if !m.connected {
+		return lipgloss.JoinVertical(lipgloss.Left, line, input, systemStyle.Render("disconnected"))
+	}
+	if typing := m.typingView(); typing != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, line, input, typing)
+	}
 	return lipgloss.JoinVertical(lipgloss.Left, line, input, line)
 }
+
+// typingView lists the users typing in the active room.
+func (m model) typingView() string {
+	now := time.Now()
+	users := []string{}
+	for username, until := range m.typing[m.currentRoom()] {
+		if until.After(now) && username != m.username {
+			users = append(users, username)
+		}
+	}
+	slices.Sort(users)
+	switch len(users) {
+	case 0:
+		return ""
+	case 1:
+		return helpStyle.Render(users[0] + " is typing…")
+	case 2:
+		return helpStyle.Render(users[0] + " and " + users[1] + " are typing…")
+	default:
+		return helpStyle.Render("several people are typing…")
+	}
+}
@@ -0,0 +1,122 @@
+// Package protocol implements the framing used to exchange msgpack encoded
+// actions over a stream connection.
+//
+// Every frame is a 4 byte big endian length followed by that many bytes of
+// payload, so the payload may contain any byte sequence.
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+const (
+	headerSize = 4
+	// MaxFrameSize is the largest payload accepted by a Reader or written by
+	// WriteFrame.
+	MaxFrameSize = 1 << 20
+)
+
+var ErrFrameTooLarge = errors.New("frame exceeds maximum size")
+
+// Reader decodes frames from an underlying stream. It keeps a single buffered
+// reader and payload buffer for the lifetime of the connection.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// ReadFrame returns the next frame payload. The returned slice is only valid
+// until the next call to ReadFrame.
+func (r *Reader) ReadFrame() ([]byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return r.buf, nil
+}
+
+// ReadAction reads the next frame and unmarshals it into an action.
+func (r *Reader) ReadAction() (types.Action, error) {
+	action := types.Action{}
+	frame, err := r.ReadFrame()
+	if err != nil {
+		return action, err
+	}
+	if _, err := action.UnmarshalMsg(frame); err != nil {
+		return action, err
+	}
+	return action, nil
+}
+
+// AppendFrame appends the framed payload to b.
+func AppendFrame(b []byte, payload []byte) ([]byte, error) {
+	if len(payload) > MaxFrameSize {
+		return b, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(payload))
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	return append(b, payload...), nil
+}
+
+// WriteFrame writes payload as a single frame. The header and payload are
+// written in one call so concurrent writers never interleave frames.
+func WriteFrame(w io.Writer, payload []byte) error {
+	frame, err := AppendFrame(make([]byte, 0, headerSize+len(payload)), payload)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
+
+// EncodeAction marshals the action and returns it as a complete frame.
+func EncodeAction(actionType types.ActionType, data []byte) ([]byte, error) {
+	action := types.Action{
+		Type: actionType,
+		Data: data,
+	}
+	b := make([]byte, headerSize, headerSize+action.Msgsize())
+	b, err := action.MarshalMsg(b)
+	if err != nil {
+		return nil, err
+	}
+	size := len(b) - headerSize
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+	binary.BigEndian.PutUint32(b, uint32(size))
+	return b, nil
+}
+
+// WriteAction marshals and writes the action as a single frame.
+func WriteAction(w io.Writer, actionType types.ActionType, data []byte) error {
+	frame, err := EncodeAction(actionType, data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(frame)
+	return err
+}
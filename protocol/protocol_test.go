@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func TestRoundTripArbitraryBytes(t *testing.T) {
+	values := []string{
+		"",
+		"hello",
+		"line one\nline two\n",
+		string([]byte{0x0a, 0x00, 0xff, 0x0a, 0x0a}),
+	}
+
+	var buf bytes.Buffer
+	for _, v := range values {
+		msg := types.Message{UserID: "id", Value: v}
+		msgB, err := msg.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteAction(&buf, types.ActionTypeMessage, msgB); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := NewReader(&buf)
+	for _, v := range values {
+		action, err := reader.ReadAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action.Type != types.ActionTypeMessage {
+			t.Fatalf("expected action type %d, got %d", types.ActionTypeMessage, action.Type)
+		}
+		msg := types.Message{}
+		if _, err := msg.UnmarshalMsg(action.Data); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Value != v {
+			t.Errorf("expected %q, got %q", v, msg.Value)
+		}
+	}
+
+	if _, err := reader.ReadFrame(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(binary.BigEndian.AppendUint32(nil, MaxFrameSize+1))
+
+	if _, err := NewReader(&buf).ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("expected ErrFrameTooLarge, got %v", err)
+	}
+}
+
+func TestWriteFrameTooLarge(t *testing.T) {
+	if err := WriteFrame(io.Discard, make([]byte, MaxFrameSize+1)); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("expected ErrFrameTooLarge, got %v", err)
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	frame, err := AppendFrame(nil, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewReader(bytes.NewReader(frame[:len(frame)-1])).ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
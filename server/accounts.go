@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrAccountExists = errors.New("account already exists")
+var ErrAccountNotFound = errors.New("account not found")
+var ErrInvalidAccountName = errors.New("account usernames cannot contain ':' or whitespace")
+
+// dummyHash is compared against when a username has no account, so failed
+// logins take the same time whether or not the account exists. It is computed
+// on first use, so commands that never authenticate do not pay for it.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("tcp-chat"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Accounts stores salted bcrypt password hashes in a file with one
+// "username:hash" line per account.
+type Accounts struct {
+	mu     sync.RWMutex
+	path   string
+	hashes map[string][]byte
+}
+
+// LoadAccounts reads the accounts file at path. A missing file is treated as
+// having no accounts.
+func LoadAccounts(path string) (*Accounts, error) {
+	a := &Accounts{path: path, hashes: map[string][]byte{}}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected username:hash", path, line)
+		}
+		a.hashes[strings.ToLower(username)] = []byte(hash)
+	}
+	return a, scanner.Err()
+}
+
+// Exists reports whether username has an account. Usernames are case
+// insensitive.
+func (a *Accounts) Exists(username string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.hashes[strings.ToLower(username)]
+	return ok
+}
+
+// Authenticate reports whether password is the password of username.
+func (a *Accounts) Authenticate(username, password string) bool {
+	a.mu.RLock()
+	hash, ok := a.hashes[strings.ToLower(username)]
+	a.mu.RUnlock()
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// validAccountName reports whether username can be stored in the accounts
+// file.
+func validAccountName(username string) bool {
+	return username != "" && !strings.ContainsFunc(username, func(r rune) bool {
+		return r == ':' || unicode.IsSpace(r)
+	})
+}
+
+// Add creates an account, or changes its password when update is set, and
+// saves the file.
+func (a *Accounts) Add(username, password string, update bool) error {
+	if !validAccountName(username) {
+		return ErrInvalidAccountName
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := strings.ToLower(username)
+	if _, ok := a.hashes[key]; ok && !update {
+		return ErrAccountExists
+	}
+	a.hashes[key] = hash
+	return a.saveLocked()
+}
+
+// Delete removes the account of username and saves the file.
+func (a *Accounts) Delete(username string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := strings.ToLower(username)
+	if _, ok := a.hashes[key]; !ok {
+		return ErrAccountNotFound
+	}
+	delete(a.hashes, key)
+	return a.saveLocked()
+}
+
+// saveLocked replaces the accounts file atomically.
+func (a *Accounts) saveLocked() error {
+	usernames := []string{}
+	for username := range a.hashes {
+		usernames = append(usernames, username)
+	}
+	slices.Sort(usernames)
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), ".accounts-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, username := range usernames {
+		fmt.Fprintf(w, "%s:%s\n", username, a.hashes[username])
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), a.path)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func TestAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts")
+	accounts, err := LoadAccounts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Add("Alice", "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Add("alice", "other", false); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("expected ErrAccountExists, got %v", err)
+	}
+
+	accounts, err = LoadAccounts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !accounts.Authenticate("alice", "secret") {
+		t.Error("expected alice to authenticate after reloading")
+	}
+	if accounts.Authenticate("alice", "wrong") {
+		t.Error("expected a wrong password to fail")
+	}
+	if accounts.Authenticate("bob", "secret") {
+		t.Error("expected an unknown user to fail")
+	}
+
+	if err := accounts.Add("alice", "changed", true); err != nil {
+		t.Fatal(err)
+	}
+	if !accounts.Authenticate("alice", "changed") {
+		t.Error("expected the updated password to authenticate")
+	}
+	if err := accounts.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if accounts.Exists("alice") {
+		t.Error("expected alice to be deleted")
+	}
+}
+
+func TestAccountsInvalidUsername(t *testing.T) {
+	accounts, err := LoadAccounts(filepath.Join(t.TempDir(), "accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"a:b", "a b", "a\nb", ""} {
+		if err := accounts.Add(username, "secret", false); !errors.Is(err, ErrInvalidAccountName) {
+			t.Errorf("expected %q to be rejected, got %v", username, err)
+		}
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	accounts, err := LoadAccounts(filepath.Join(t.TempDir(), "accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Add("alice", "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t)
+	cfg.accounts = accounts
+	cfg.authRequired = true
+	conn := dial(t, startServerWith(t, cfg))
+	reader := protocol.NewReader(conn)
+
+	expectError := func(code types.ErrorCode) {
+		t.Helper()
+		action := readUntil(t, conn, reader, types.ActionTypeError)
+		errMsg := types.ErrorMessage{}
+		if _, err := errMsg.UnmarshalMsg(action.Data); err != nil {
+			t.Fatal(err)
+		}
+		if errMsg.Code != code {
+			t.Errorf("expected error code %d, got %+v", code, errMsg)
+		}
+	}
+
+	registerB, _ := (&types.Register{Username: "alice"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeRegister, registerB)
+	expectError(types.ErrorCodeAuthRequired)
+
+	messageB, _ := (&types.Message{Value: "hi"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeMessage, messageB)
+	expectError(types.ErrorCodeNotRegistered)
+
+	wrongB, _ := (&types.Credentials{Username: "alice", Password: "wrong"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeLogin, wrongB)
+	expectError(types.ErrorCodeBadCredentials)
+
+	credentialsB, _ := (&types.Credentials{Username: "alice", Password: "secret"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeLogin, credentialsB)
+	if session := readSession(t, conn, reader); session.UserID == "" {
+		t.Errorf("expected a session after logging in, got %+v", session)
+	}
+}
+
+func TestModeratorDeletes(t *testing.T) {
+	accounts, err := LoadAccounts(filepath.Join(t.TempDir(), "accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Add("mod", "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t)
+	cfg.accounts = accounts
+	cfg.moderators = map[string]bool{"mod": true}
+	address := startServerWith(t, cfg)
+
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	messageB, _ := (&types.Message{Value: "spam", Nonce: "1"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeMessage, messageB)
+	ack := types.Ack{}
+	ack.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeAck).Data)
+
+	mod := dial(t, address)
+	modReader := protocol.NewReader(mod)
+	credentialsB, _ := (&types.Credentials{Username: "mod", Password: "secret"}).MarshalMsg(nil)
+	protocol.WriteAction(mod, types.ActionTypeLogin, credentialsB)
+	readUntil(t, mod, modReader, types.ActionTypeGetUsers)
+
+	deleteB, _ := (&types.Message{ID: ack.ID}).MarshalMsg(nil)
+	protocol.WriteAction(mod, types.ActionTypeDelete, deleteB)
+	deleted := types.Message{}
+	deleted.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeDelete).Data)
+	if deleted.ID != ack.ID || !deleted.Deleted {
+		t.Errorf("expected the moderator to delete message %d, got %+v", ack.ID, deleted)
+	}
+}
+
+func TestRenamedAccountNotModerator(t *testing.T) {
+	accounts, err := LoadAccounts(filepath.Join(t.TempDir(), "accounts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Add("alice", "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t)
+	cfg.accounts = accounts
+	cfg.moderators = map[string]bool{"mod": true}
+	address := startServerWith(t, cfg)
+
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	messageB, _ := (&types.Message{Value: "hello", Nonce: "1"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeMessage, messageB)
+	ack := types.Ack{}
+	ack.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeAck).Data)
+
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	credentialsB, _ := (&types.Credentials{Username: "alice", Password: "secret"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeLogin, credentialsB)
+	readUntil(t, alice, aliceReader, types.ActionTypeGetUsers)
+
+	// mod is a moderator without an account, so alice may take its name.
+	renameB, _ := (&types.User{Username: "mod"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeRename, renameB)
+	if presence := readPresence(t, bob, bobReader); presence.Type != types.PresenceJoined {
+		t.Fatalf("expected alice to join, got %+v", presence)
+	}
+	if presence := readPresence(t, bob, bobReader); presence.Type != types.PresenceRenamed || presence.User.Username != "mod" {
+		t.Fatalf("expected alice to be renamed to mod, got %+v", presence)
+	}
+
+	deleteB, _ := (&types.Message{ID: ack.ID}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeDelete, deleteB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeForbidden {
+		t.Errorf("expected the renamed user not to be a moderator, got %+v", errMsg)
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// apiPath is the prefix of the endpoints of the HTTP API:
+//
+//	GET    /api/users                      online users
+//	DELETE /api/users/{user}               kick a user, by ID or username
+//	GET    /api/rooms                      rooms and their users
+//	GET    /api/rooms/{room}/history       recent messages, ?before=&limit=
+//	POST   /api/rooms/{room}/messages      post {"value": ...} as the service account
+//
+// Every request needs the header "Authorization: Bearer <token>". Responses
+// are JSON objects with the same keys as the msgpack encoding of their types,
+// and errors are types.ErrorMessage objects.
+const apiPath = "/api"
+
+// defaultAPIUsername is the name of the service account posting the messages
+// of the API.
+const defaultAPIUsername = "api"
+
+// apiHandler serves the HTTP API from hub, posting messages as bot.
+func apiHandler(hub *Hub, bot *Bot, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPath+"/users", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		users := hub.Users()
+		writeAPI(w, http.StatusOK, &users)
+	})
+	mux.HandleFunc(apiPath+"/users/", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodDelete) {
+			return
+		}
+		user := strings.TrimPrefix(r.URL.Path, apiPath+"/users/")
+		if !hub.Kick(user) {
+			writeAPIError(w, http.StatusNotFound, types.ErrorCodeRecipientOffline, "user "+user+" is offline")
+			return
+		}
+		log.Println("Kicked user: " + user)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc(apiPath+"/rooms", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		rooms := hub.Rooms()
+		writeAPI(w, http.StatusOK, &rooms)
+	})
+	mux.HandleFunc(apiPath+"/rooms/", func(w http.ResponseWriter, r *http.Request) {
+		room, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, apiPath+"/rooms/"), "/")
+		if !validRoomName(room) {
+			writeAPIError(w, http.StatusNotFound, types.ErrorCodeInvalidRoom, "invalid room name "+room)
+			return
+		}
+		switch endpoint {
+		case "history":
+			if allowMethod(w, r, http.MethodGet) {
+				getHistory(w, r, hub, room)
+			}
+		case "messages":
+			if allowMethod(w, r, http.MethodPost) {
+				postAPIMessage(w, r, bot, room)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return requireToken(token, mux)
+}
+
+// requireToken only passes requests carrying the bearer token to next.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, types.ErrorCodeAuthRequired, "invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeAPIError(w, http.StatusMethodNotAllowed, types.ErrorCodeUnknownAction, "method "+r.Method+" not allowed")
+	return false
+}
+
+func getHistory(w http.ResponseWriter, r *http.Request, hub *Hub, room string) {
+	query := r.URL.Query()
+	limit := maxHistoryPage
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, types.ErrorCodeMalformedAction, "invalid limit "+value)
+			return
+		}
+		limit = min(max(n, 1), maxHistoryPage)
+	}
+	var before uint64
+	if value := query.Get("before"); value != "" {
+		var err error
+		if before, err = strconv.ParseUint(value, 10, 64); err != nil {
+			writeAPIError(w, http.StatusBadRequest, types.ErrorCodeMalformedAction, "invalid before "+value)
+			return
+		}
+	}
+	page, err := historyPage(hub.history, room, before, limit)
+	if err != nil {
+		log.Print("Error reading history: " + err.Error())
+		writeAPIError(w, http.StatusInternalServerError, types.ErrorCodeUnknown, "error reading history")
+		return
+	}
+	writeAPI(w, http.StatusOK, &page)
+}
+
+func postAPIMessage(w http.ResponseWriter, r *http.Request, bot *Bot, room string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, protocol.MaxFrameSize))
+	if err != nil {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, types.ErrorCodeMalformedAction, "message too large")
+		return
+	}
+	request := types.Message{}
+	if err := json.Unmarshal(body, &request); err != nil || request.Value == "" {
+		writeAPIError(w, http.StatusBadRequest, types.ErrorCodeMalformedAction, "expected a JSON object with a value")
+		return
+	}
+	message := bot.Post(room, request.Value)
+	log.Printf("Posted API message %d to room %s", message.ID, room)
+	writeAPI(w, http.StatusCreated, &message)
+}
+
+// writeAPI writes v as JSON.
+func writeAPI(w http.ResponseWriter, status int, v msgp.Marshaler) {
+	body, err := marshalJSON(v)
+	if err != nil {
+		log.Print("Error marshalling response: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// marshalJSON encodes v as JSON, with the keys of its msgpack encoding.
+func marshalJSON(v msgp.Marshaler) ([]byte, error) {
+	vB, err := v.MarshalMsg(nil)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if _, err := msgp.UnmarshalAsJSON(&body, vB); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code types.ErrorCode, value string) {
+	writeAPI(w, status, &types.ErrorMessage{Code: code, Value: value})
+}
+
+// newAPIServer returns the HTTP server of the API, with timeouts so idle or
+// slow integrations do not hold connections forever.
+func newAPIServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+const testAPIToken = "secret"
+
+// startAPI serves one hub over TCP and the HTTP API, returning the TCP address
+// and the base URL of the API.
+func startAPI(t *testing.T) (string, string) {
+	t.Helper()
+	cfg := testConfig(t)
+	hub, err := newHub(NewMemoryHistory(10), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(apiHandler(hub, hub.AddBot(defaultAPIUsername, nil), testAPIToken))
+	t.Cleanup(server.Close)
+	return listenTCP(t, hub, cfg), server.URL + apiPath
+}
+
+// callAPI sends a request with the test token and decodes the JSON response
+// into v, when it is not nil.
+func callAPI(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respB, _ := io.ReadAll(resp.Body)
+	if v != nil {
+		if err := json.Unmarshal(respB, v); err != nil {
+			t.Fatalf("decoding %q: %v", respB, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	_, url := startAPI(t)
+	for _, authorization := range []string{"", "Bearer wrong", testAPIToken} {
+		req, _ := http.NewRequest(http.MethodGet, url+"/users", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected %q to be unauthorized, got %d", authorization, resp.StatusCode)
+		}
+	}
+}
+
+func TestAPIUsersAndRooms(t *testing.T) {
+	address, url := startAPI(t)
+	alice := dial(t, address)
+	register(t, alice, protocol.NewReader(alice), "alice")
+
+	users := []types.User{}
+	if status := callAPI(t, http.MethodGet, url+"/users", "", &users); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if len(users) != 1 || users[0].Username != "alice" {
+		t.Errorf("expected alice online, got %+v", users)
+	}
+
+	rooms := []types.Room{}
+	callAPI(t, http.MethodGet, url+"/rooms", "", &rooms)
+	if len(rooms) != 1 || rooms[0].Name != types.DefaultRoom || len(rooms[0].Users) != 1 {
+		t.Errorf("expected alice in %s, got %+v", types.DefaultRoom, rooms)
+	}
+
+	if status := callAPI(t, http.MethodPost, url+"/users", "", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", status)
+	}
+}
+
+func TestAPIPostMessage(t *testing.T) {
+	address, url := startAPI(t)
+	alice := dial(t, address)
+	reader := protocol.NewReader(alice)
+	register(t, alice, reader, "alice")
+
+	posted := types.Message{}
+	status := callAPI(t, http.MethodPost, url+"/rooms/"+types.DefaultRoom+"/messages", `{"value": "build passed"}`, &posted)
+	if status != http.StatusCreated || posted.ID == 0 || posted.Username != defaultAPIUsername {
+		t.Fatalf("expected the message to be created, got %d %+v", status, posted)
+	}
+	message := types.Message{}
+	message.UnmarshalMsg(readUntil(t, alice, reader, types.ActionTypeMessage).Data)
+	if message.ID != posted.ID || message.Value != "build passed" {
+		t.Errorf("expected the posted message, got %+v", message)
+	}
+
+	history := types.History{}
+	callAPI(t, http.MethodGet, url+"/rooms/"+types.DefaultRoom+"/history?limit=5", "", &history)
+	if len(history.Messages) != 1 || history.Messages[0].ID != posted.ID {
+		t.Errorf("expected the posted message in the history, got %+v", history)
+	}
+
+	errMsg := types.ErrorMessage{}
+	if status := callAPI(t, http.MethodPost, url+"/rooms/"+types.DefaultRoom+"/messages", `{}`, &errMsg); status != http.StatusBadRequest || errMsg.Code != types.ErrorCodeMalformedAction {
+		t.Errorf("expected an empty message to be rejected, got %d %+v", status, errMsg)
+	}
+
+	other := dial(t, address)
+	otherReader := protocol.NewReader(other)
+	registerB, _ := (&types.Register{Username: defaultAPIUsername}).MarshalMsg(nil)
+	protocol.WriteAction(other, types.ActionTypeRegister, registerB)
+	errMsg.UnmarshalMsg(readUntil(t, other, otherReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeUsernameTaken {
+		t.Errorf("expected the service account name to be taken, got %+v", errMsg)
+	}
+}
+
+func TestAPIKick(t *testing.T) {
+	address, url := startAPI(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	readPresence(t, alice, aliceReader)
+
+	if status := callAPI(t, http.MethodDelete, url+"/users/bob", "", nil); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeKicked {
+		t.Errorf("expected a kicked error for bob, got %+v", errMsg)
+	}
+	if _, err := bobReader.ReadFrame(); err == nil {
+		t.Error("expected the connection of bob to be closed")
+	}
+	if presence := readPresence(t, alice, aliceReader); presence.Type != types.PresenceLeft || presence.User.Username != "bob" {
+		t.Errorf("expected bob to leave, got %+v", presence)
+	}
+	if status := callAPI(t, http.MethodDelete, url+"/users/bob", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for an offline user, got %d", status)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+const (
+	// maxDice and maxDieSides bound the rolls of the dice plugin.
+	maxDice     = 20
+	maxDieSides = 1000
+)
+
+func init() {
+	RegisterPlugin("echo", func() Plugin { return echoPlugin{} })
+	RegisterPlugin("dice", func() Plugin { return &dicePlugin{roll: rand.Intn} })
+}
+
+// echoPlugin repeats the messages starting with "!echo ".
+type echoPlugin struct{}
+
+func (echoPlugin) HandleMessage(bot *Bot, message types.Message) {
+	if value, ok := strings.CutPrefix(message.Value, "!echo "); ok {
+		bot.Post(message.Room, value)
+	}
+}
+
+func (echoPlugin) HandlePresence(*Bot, types.Presence) {}
+
+// dicePlugin answers "!roll NdM" with the sum of N dice of M sides, one six
+// sided die by default.
+type dicePlugin struct {
+	// roll returns a number in [0, n).
+	roll func(n int) int
+}
+
+func (p *dicePlugin) HandleMessage(bot *Bot, message types.Message) {
+	fields := strings.Fields(message.Value)
+	if len(fields) == 0 || fields[0] != "!roll" {
+		return
+	}
+	spec := "1d6"
+	if len(fields) > 1 {
+		spec = fields[1]
+	}
+	dice, sides, err := parseDice(spec)
+	if err != nil {
+		bot.Post(message.Room, err.Error())
+		return
+	}
+	rolls := []string{}
+	total := 0
+	for i := 0; i < dice; i++ {
+		n := p.roll(sides) + 1
+		total += n
+		rolls = append(rolls, strconv.Itoa(n))
+	}
+	bot.Post(message.Room, fmt.Sprintf("%s rolled %s: %d (%s)", message.Username, spec, total, strings.Join(rolls, " + ")))
+}
+
+func (p *dicePlugin) HandlePresence(*Bot, types.Presence) {}
+
+// parseDice parses dice specs like "2d6".
+func parseDice(spec string) (dice, sides int, err error) {
+	d, s, ok := strings.Cut(strings.ToLower(spec), "d")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid dice %q, expected NdM like 2d6", spec)
+	}
+	if d == "" {
+		d = "1"
+	}
+	dice, err = strconv.Atoi(d)
+	if err != nil || dice < 1 || dice > maxDice {
+		return 0, 0, fmt.Errorf("number of dice must be between 1 and %d", maxDice)
+	}
+	sides, err = strconv.Atoi(s)
+	if err != nil || sides < 2 || sides > maxDieSides {
+		return 0, 0, fmt.Errorf("number of sides must be between 2 and %d", maxDieSides)
+	}
+	return dice, sides, nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// OverflowPolicy decides what happens to a frame sent to a client whose write
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest queued frame to make room.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the frame being sent.
+	DropNewest
+	// Disconnect closes the connection of the slow client.
+	Disconnect
+)
+
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch policy {
+	case "drop-oldest":
+		return DropOldest, nil
+	case "drop-newest":
+		return DropNewest, nil
+	case "disconnect":
+		return Disconnect, nil
+	}
+	return 0, fmt.Errorf("unknown slow consumer policy %q", policy)
+}
+
+// QueueConfig configures the outbound queue of every client.
+type QueueConfig struct {
+	Size         int
+	WriteTimeout time.Duration
+	Policy       OverflowPolicy
+}
+
+var DefaultQueueConfig = QueueConfig{
+	Size:         256,
+	WriteTimeout: 10 * time.Second,
+	Policy:       DropOldest,
+}
+
+// closeTimeout bounds the time CloseAfter waits for the last frames of a
+// client to be written.
+const closeTimeout = 5 * time.Second
+
+var (
+	ErrQueueFull    = errors.New("client write queue is full")
+	ErrSlowConsumer = errors.New("client disconnected for being too slow")
+	ErrClientClosed = errors.New("client is closed")
+)
+
+// Client is a connection attached to a Hub. Frames sent to it are queued and
+// written by a dedicated goroutine, so a stalled connection never blocks the
+// sender. Its Username is empty until the client registers. Its ID, Username
+// and session are only changed by the Hub while holding its lock.
+type Client struct {
+	types.User
+	// session is the token of the resumable session of the client.
+	session   string
+	cfg       QueueConfig
+	queue     chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+	// hubDropped counts the frames dropped by all clients of the hub.
+	hubDropped *atomic.Uint64
+	metrics    *metrics
+}
+
+func newClient(user types.User, cfg QueueConfig, hubDropped *atomic.Uint64, metrics *metrics) *Client {
+	c := &Client{
+		User:       user,
+		cfg:        cfg,
+		queue:      make(chan []byte, max(cfg.Size, 1)),
+		done:       make(chan struct{}),
+		hubDropped: hubDropped,
+		metrics:    metrics,
+	}
+	go c.writeLoop()
+	return c
+}
+
+// Send queues a single action for the client.
+func (c *Client) Send(actionType types.ActionType, data []byte) error {
+	frame, err := protocol.EncodeAction(actionType, data)
+	if err != nil {
+		return err
+	}
+	return c.sendFrame(actionType, frame)
+}
+
+func (c *Client) sendFrame(actionType types.ActionType, frame []byte) error {
+	err := c.queueFrame(frame)
+	if err == nil {
+		c.metrics.actionsOut.WithLabelValues(actionType.String()).Inc()
+	}
+	return err
+}
+
+func (c *Client) queueFrame(frame []byte) error {
+	select {
+	case <-c.done:
+		return ErrClientClosed
+	default:
+	}
+	select {
+	case c.queue <- frame:
+		return nil
+	default:
+	}
+
+	switch c.cfg.Policy {
+	case DropOldest:
+		select {
+		case <-c.queue:
+			c.drop()
+		default:
+		}
+		select {
+		case c.queue <- frame:
+			return nil
+		default:
+			c.drop()
+			return ErrQueueFull
+		}
+	case Disconnect:
+		c.drop()
+		c.Close()
+		return ErrSlowConsumer
+	default:
+		c.drop()
+		return ErrQueueFull
+	}
+}
+
+func (c *Client) drop() {
+	c.dropped.Add(1)
+	if c.hubDropped != nil {
+		c.hubDropped.Add(1)
+	}
+}
+
+// Dropped returns the number of frames dropped because the queue was full.
+func (c *Client) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+func (c *Client) writeLoop() {
+	for {
+		select {
+		case frame := <-c.queue:
+			if len(frame) == 0 {
+				// Queued by CloseAfter.
+				c.Close()
+				return
+			}
+			if c.cfg.WriteTimeout > 0 {
+				c.GetConn().SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+			}
+			if _, err := c.GetConn().Write(frame); err != nil {
+				c.metrics.writeErrors.Inc()
+				c.Close()
+				return
+			}
+			c.metrics.bytesOut.Add(float64(len(frame)))
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close stops the writer and closes the connection, which also ends the read
+// loop of the client.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.GetConn().Close()
+	})
+}
+
+// CloseAfter sends a last action to the client and closes it once the frames
+// queued so far are written, or after closeTimeout when they cannot be.
+func (c *Client) CloseAfter(actionType types.ActionType, data []byte) {
+	if c.Send(actionType, data) != nil || c.queueFrame(nil) != nil {
+		c.Close()
+		return
+	}
+	time.AfterFunc(closeTimeout, c.Close)
+}
+
+// Done is closed once the client is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
@@ -0,0 +1,26 @@
+package server
+
+import "encoding/binary"
+
+// frameBuffer reassembles the frames written by a Client to the connections
+// of the gateways, which translate every frame to their own protocol.
+type frameBuffer struct {
+	buf []byte
+}
+
+// write calls send with every complete frame of p, keeping any trailing
+// partial frame for the next call.
+func (b *frameBuffer) write(p []byte, send func(frame []byte) error) (int, error) {
+	b.buf = append(b.buf, p...)
+	for len(b.buf) >= 4 {
+		size := int(binary.BigEndian.Uint32(b.buf))
+		if len(b.buf) < 4+size {
+			break
+		}
+		if err := send(b.buf[4 : 4+size]); err != nil {
+			return 0, err
+		}
+		b.buf = b.buf[4+size:]
+	}
+	return len(p), nil
+}
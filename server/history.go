@@ -0,0 +1,226 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// HistoryEntry is a stored message together with its sequence number. Sequence
+// numbers start at 1 and grow by one with every appended message.
+type HistoryEntry struct {
+	Seq     uint64
+	Message types.Message
+}
+
+// ErrMessageNotFound is returned when updating a message that is not kept
+// anymore.
+var ErrMessageNotFound = types.ErrorMessage{Code: types.ErrorCodeMessageNotFound, Value: "message not found"}
+
+// HistoryStore keeps the messages sent to rooms so they can be replayed to
+// users joining later.
+type HistoryStore interface {
+	// Append stores message and returns its sequence number, which is also
+	// the ID of the message.
+	Append(message types.Message) (uint64, error)
+	// Before returns up to limit messages of room with a sequence number lower
+	// than before, oldest first. A zero before returns the most recent
+	// messages.
+	Before(room string, before uint64, limit int) ([]HistoryEntry, error)
+	// Get returns the message identified by id or ErrMessageNotFound.
+	Get(id uint64) (types.Message, error)
+	// Update calls update with the message identified by id and stores the
+	// result, unless update returns an error. It returns the updated message
+	// or ErrMessageNotFound.
+	Update(id uint64, update func(*types.Message) error) (types.Message, error)
+	Close() error
+}
+
+// MemoryHistory is a HistoryStore that keeps the last messages of all rooms in
+// a fixed size ring buffer.
+type MemoryHistory struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	seq     uint64
+}
+
+func NewMemoryHistory(size int) *MemoryHistory {
+	return &MemoryHistory{entries: make([]HistoryEntry, 0, size)}
+}
+
+func (h *MemoryHistory) Append(message types.Message) (uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	message.ID = h.seq
+	h.appendLocked(HistoryEntry{Seq: h.seq, Message: message})
+	return h.seq, nil
+}
+
+func (h *MemoryHistory) appendLocked(entry HistoryEntry) {
+	if cap(h.entries) == 0 {
+		return
+	}
+	if len(h.entries) < cap(h.entries) {
+		h.entries = append(h.entries, entry)
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+}
+
+func (h *MemoryHistory) Before(room string, before uint64, limit int) ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	page := []HistoryEntry{}
+	for i := len(h.entries) - 1; i >= 0 && len(page) < limit; i-- {
+		entry := h.entries[(h.next+i)%len(h.entries)]
+		if entry.Message.Room != room || (before != 0 && entry.Seq >= before) {
+			continue
+		}
+		page = append(page, entry)
+	}
+	for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+		page[i], page[j] = page[j], page[i]
+	}
+	return page, nil
+}
+
+func (h *MemoryHistory) Get(id uint64) (types.Message, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, entry := range h.entries {
+		if entry.Seq == id {
+			return entry.Message, nil
+		}
+	}
+	return types.Message{}, ErrMessageNotFound
+}
+
+func (h *MemoryHistory) Update(id uint64, update func(*types.Message) error) (types.Message, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.updateLocked(id, update)
+}
+
+func (h *MemoryHistory) updateLocked(id uint64, update func(*types.Message) error) (types.Message, error) {
+	for i := range h.entries {
+		if h.entries[i].Seq != id {
+			continue
+		}
+		message := h.entries[i].Message
+		if err := update(&message); err != nil {
+			return types.Message{}, err
+		}
+		h.entries[i].Message = message
+		return message, nil
+	}
+	return types.Message{}, ErrMessageNotFound
+}
+
+func (h *MemoryHistory) Close() error {
+	return nil
+}
+
+// FileHistory is a HistoryStore that appends every message to a file, so the
+// history survives restarts. The most recent messages are kept in memory to
+// answer queries.
+//
+// New messages are stored without an ID, which is implied by their position
+// in the file. Updates are appended as the whole updated message, with its ID.
+type FileHistory struct {
+	*MemoryHistory
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileHistory opens or creates the history file at path and loads the last
+// size messages from it. A partial frame at the end of the file, left by a
+// crash or a full disk, is truncated away.
+func OpenFileHistory(path string, size int) (*FileHistory, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	memory := NewMemoryHistory(size)
+	reader := protocol.NewReader(file)
+	// offset is the end of the last complete frame.
+	var offset int64
+	for {
+		frame, err := reader.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("Truncating partial frame at offset %d of history file %s", offset, path)
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("truncating %s: %w", path, err)
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		offset += int64(4 + len(frame))
+		message := types.Message{}
+		if _, err := message.UnmarshalMsg(frame); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if message.ID != 0 {
+			memory.updateLocked(message.ID, func(m *types.Message) error {
+				*m = message
+				return nil
+			})
+			continue
+		}
+		memory.seq++
+		message.ID = memory.seq
+		memory.appendLocked(HistoryEntry{Seq: memory.seq, Message: message})
+	}
+	return &FileHistory{MemoryHistory: memory, file: file}, nil
+}
+
+func (h *FileHistory) Append(message types.Message) (uint64, error) {
+	message.ID = 0
+	messageB, err := message.MarshalMsg(nil)
+	if err != nil {
+		return 0, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := protocol.WriteFrame(h.file, messageB); err != nil {
+		return 0, err
+	}
+	return h.MemoryHistory.Append(message)
+}
+
+func (h *FileHistory) Update(id uint64, update func(*types.Message) error) (types.Message, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	message, err := h.MemoryHistory.Update(id, update)
+	if err != nil {
+		return types.Message{}, err
+	}
+	messageB, err := message.MarshalMsg(nil)
+	if err != nil {
+		return types.Message{}, err
+	}
+	if err := protocol.WriteFrame(h.file, messageB); err != nil {
+		return types.Message{}, err
+	}
+	return message, nil
+}
+
+func (h *FileHistory) Close() error {
+	return h.file.Close()
+}
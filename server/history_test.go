@@ -0,0 +1,186 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func appendMessages(t *testing.T, history HistoryStore, room string, values ...string) {
+	t.Helper()
+	for _, v := range values {
+		if _, err := history.Append(types.Message{Room: room, Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func entryValues(entries []HistoryEntry) []string {
+	values := []string{}
+	for _, e := range entries {
+		values = append(values, e.Message.Value)
+	}
+	return values
+}
+
+func assertValues(t *testing.T, entries []HistoryEntry, expected ...string) {
+	t.Helper()
+	values := entryValues(entries)
+	if len(values) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+	for i := range values {
+		if values[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, values)
+		}
+	}
+}
+
+func TestMemoryHistoryPaging(t *testing.T) {
+	history := NewMemoryHistory(10)
+	appendMessages(t, history, "general", "1", "2", "3", "4", "5")
+	appendMessages(t, history, "other", "x")
+
+	page, err := history.Before("general", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "4", "5")
+
+	page, err = history.Before("general", page[0].Seq, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "2", "3")
+
+	page, err = history.Before("general", page[0].Seq, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "1")
+}
+
+func TestMemoryHistoryRingBuffer(t *testing.T) {
+	history := NewMemoryHistory(3)
+	appendMessages(t, history, "general", "1", "2", "3", "4", "5")
+
+	page, err := history.Before("general", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "3", "4", "5")
+	if page[0].Seq != 3 {
+		t.Errorf("expected sequence 3, got %d", page[0].Seq)
+	}
+}
+
+func TestFileHistoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	history, err := OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, history, "general", "1", "line\nbreak")
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	appendMessages(t, history, "general", "3")
+
+	page, err := history.Before("general", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "1", "line\nbreak", "3")
+	if page[2].Seq != 3 {
+		t.Errorf("expected sequence 3, got %d", page[2].Seq)
+	}
+}
+
+func TestFileHistoryTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history, err := OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, history, "general", "1", "2")
+	history.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 0, 9, 0x81, 0xa2})
+	file.Close()
+
+	history, err = OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatalf("expected the torn tail to be skipped, got %v", err)
+	}
+	appendMessages(t, history, "general", "3")
+	history.Close()
+
+	// The message appended after the truncation is read back intact.
+	history, err = OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	page, err := history.Before("general", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "1", "2", "3")
+}
+
+func TestFileHistoryUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	history, err := OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, history, "general", "1", "2")
+	edited, err := history.Update(1, func(m *types.Message) error {
+		m.Value = "one"
+		m.Edited = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.ID != 1 || edited.Value != "one" || !edited.Edited {
+		t.Errorf("expected message 1 to be edited, got %+v", edited)
+	}
+	if _, err := history.Update(7, func(*types.Message) error { return nil }); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("expected ErrMessageNotFound, got %v", err)
+	}
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = OpenFileHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	appendMessages(t, history, "general", "3")
+
+	page, err := history.Before("general", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, page, "one", "2", "3")
+	if !page[0].Message.Edited || page[2].Message.ID != 3 {
+		t.Errorf("expected the edit to survive reopening, got %+v", page)
+	}
+}
@@ -0,0 +1,319 @@
+package server
+
+import (
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// Hub owns the connected clients, their usernames and the rooms they are in.
+// All of its state is guarded by one mutex, which is never held while writing
+// to a connection.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+	rooms   map[string]map[string]struct{}
+	history HistoryStore
+	queue   QueueConfig
+	dropped atomic.Uint64
+	// sessions maps resume tokens to the sessions of registered users.
+	sessions map[string]*session
+	// SessionTTL is how long the session of a disconnected user can be
+	// resumed.
+	SessionTTL time.Duration
+	// bots are the plugins running as virtual users.
+	bots    []*Bot
+	metrics *metrics
+}
+
+func NewHub(history HistoryStore, queue QueueConfig) *Hub {
+	h := &Hub{
+		clients: map[string]*Client{},
+		rooms: map[string]map[string]struct{}{
+			types.DefaultRoom: {},
+		},
+		history:    history,
+		queue:      queue,
+		sessions:   map[string]*session{},
+		SessionTTL: defaultSessionTTL,
+	}
+	h.metrics = newMetrics(h)
+	return h
+}
+
+// DroppedFrames returns the number of frames dropped by slow clients.
+func (h *Hub) DroppedFrames() uint64 {
+	return h.dropped.Load()
+}
+
+// Connect attaches conn to the hub as an unregistered client.
+func (h *Hub) Connect(conn net.Conn) *Client {
+	c := newClient(types.NewUser(uuid.New().String(), "", conn), h.queue, &h.dropped, h.metrics)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c.ID] = c
+	return c
+}
+
+// Register sets the username of c and joins it to types.DefaultRoom. It fails
+// with a types.ErrorMessage when the username is already taken.
+func (h *Hub) Register(c *Client, username string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.usernameTakenLocked(username, c.ID) {
+		return types.ErrorMessage{Code: types.ErrorCodeUsernameTaken, Value: "username " + username + " is already taken"}
+	}
+	c.Username = username
+	h.joinLocked(types.DefaultRoom, c.ID)
+	return nil
+}
+
+// Rename changes the username of a registered client. It fails with a
+// types.ErrorMessage when the username is already taken.
+func (h *Hub) Rename(c *Client, username string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.usernameTakenLocked(username, c.ID) {
+		return types.ErrorMessage{Code: types.ErrorCodeUsernameTaken, Value: "username " + username + " is already taken"}
+	}
+	c.Username = username
+	return nil
+}
+
+// Unregister detaches c from the hub and every room, returning the rooms it
+// has left. The session of c, if any, can be resumed for SessionTTL. Nothing
+// happens when c was replaced by a client resuming its session.
+func (h *Hub) Unregister(c *Client) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c.ID] != c {
+		return nil
+	}
+	delete(h.clients, c.ID)
+	left := []string{}
+	for room := range h.rooms {
+		if h.leaveLocked(room, c.ID) {
+			left = append(left, room)
+		}
+	}
+	h.suspendLocked(c, left)
+	return left
+}
+
+// Kick disconnects the registered client identified by idOrUsername and
+// discards its session, so it cannot be resumed. The client is told with an
+// ErrorCodeKicked error so it does not reconnect. It reports whether the client
+// was found.
+func (h *Hub) Kick(idOrUsername string) bool {
+	c, ok := h.FindUser(idOrUsername)
+	if !ok {
+		return false
+	}
+	h.mu.Lock()
+	delete(h.sessions, c.session)
+	c.session = ""
+	h.mu.Unlock()
+	errB, _ := (&types.ErrorMessage{Code: types.ErrorCodeKicked, Value: "kicked by an operator"}).MarshalMsg(nil)
+	c.CloseAfter(types.ActionTypeError, errB)
+	return true
+}
+
+// usernameTakenLocked reports whether a client other than id, or a bot, uses
+// username. Names are compared case insensitively.
+func (h *Hub) usernameTakenLocked(username, id string) bool {
+	for _, c := range h.clients {
+		if c.ID != id && strings.EqualFold(c.Username, username) {
+			return true
+		}
+	}
+	return slices.ContainsFunc(h.bots, func(b *Bot) bool { return strings.EqualFold(b.Username, username) })
+}
+
+// Join adds c to room and reports whether it was not a member yet.
+func (h *Hub) Join(c *Client, room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.joinLocked(room, c.ID)
+}
+
+func (h *Hub) joinLocked(room, id string) bool {
+	if _, ok := h.rooms[room]; !ok {
+		h.rooms[room] = map[string]struct{}{}
+	}
+	if _, ok := h.rooms[room][id]; ok {
+		return false
+	}
+	h.rooms[room][id] = struct{}{}
+	return true
+}
+
+// Leave removes c from room and reports whether it was a member.
+func (h *Hub) Leave(c *Client, room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.leaveLocked(room, c.ID)
+}
+
+// leaveLocked removes id from room. Empty rooms are removed, except for
+// types.DefaultRoom which always exists.
+func (h *Hub) leaveLocked(room, id string) bool {
+	members, ok := h.rooms[room]
+	if !ok {
+		return false
+	}
+	if _, ok := members[id]; !ok {
+		return false
+	}
+	delete(members, id)
+	if len(members) == 0 && room != types.DefaultRoom {
+		delete(h.rooms, room)
+	}
+	return true
+}
+
+func (h *Hub) IsMember(room, id string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.rooms[room][id]
+	return ok
+}
+
+// RoomsOf returns the rooms id is a member of.
+func (h *Hub) RoomsOf(id string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := []string{}
+	for room, members := range h.rooms {
+		if _, ok := members[id]; ok {
+			names = append(names, room)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Room returns room together with the users in it.
+func (h *Hub) Room(room string) types.Room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.roomLocked(room)
+}
+
+func (h *Hub) roomLocked(room string) types.Room {
+	r := types.Room{Name: room, Users: types.Users{}}
+	for id := range h.rooms[room] {
+		if c, ok := h.clients[id]; ok {
+			r.Users = append(r.Users, types.User{ID: c.ID, Username: c.Username})
+		}
+	}
+	return r
+}
+
+// Rooms returns every room together with the users in it, sorted by name.
+func (h *Hub) Rooms() types.Rooms {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := types.Rooms{}
+	for room := range h.rooms {
+		rooms = append(rooms, h.roomLocked(room))
+	}
+	slices.SortFunc(rooms, func(a, b types.Room) int { return strings.Compare(a.Name, b.Name) })
+	return rooms
+}
+
+// connectedClients returns the number of clients, registered or not.
+func (h *Hub) connectedClients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Users returns every registered user.
+func (h *Hub) Users() types.Users {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	users := types.Users{}
+	for _, c := range h.clients {
+		if c.Username != "" {
+			users = append(users, types.User{ID: c.ID, Username: c.Username})
+		}
+	}
+	return users
+}
+
+// FindUser looks up a registered client by ID or username. Usernames are
+// compared case insensitively, as they are unique regardless of case.
+func (h *Hub) FindUser(idOrUsername string) (*Client, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if c, ok := h.clients[idOrUsername]; ok && c.Username != "" {
+		return c, true
+	}
+	for _, c := range h.clients {
+		if c.Username != "" && strings.EqualFold(c.Username, idOrUsername) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Broadcast sends an action to every member of room except the client with
+// the ID except, which may be empty.
+func (h *Hub) Broadcast(room, except string, actionType types.ActionType, data []byte) {
+	defer h.metrics.observeBroadcast(actionType, time.Now())
+	h.mu.RLock()
+	recipients := []*Client{}
+	for id := range h.rooms[room] {
+		if c, ok := h.clients[id]; ok && id != except {
+			recipients = append(recipients, c)
+		}
+	}
+	h.mu.RUnlock()
+	h.send(recipients, actionType, data)
+}
+
+// BroadcastShared sends an action to id and every client sharing a room with
+// it.
+func (h *Hub) BroadcastShared(id string, actionType types.ActionType, data []byte) {
+	defer h.metrics.observeBroadcast(actionType, time.Now())
+	h.mu.RLock()
+	seen := map[string]struct{}{}
+	recipients := []*Client{}
+	for _, members := range h.rooms {
+		if _, ok := members[id]; !ok {
+			continue
+		}
+		for member := range members {
+			if _, ok := seen[member]; ok {
+				continue
+			}
+			seen[member] = struct{}{}
+			if c, ok := h.clients[member]; ok {
+				recipients = append(recipients, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+	h.send(recipients, actionType, data)
+}
+
+func (h *Hub) send(recipients []*Client, actionType types.ActionType, data []byte) {
+	frame, err := protocol.EncodeAction(actionType, data)
+	if err != nil {
+		log.Print("Error encoding action: " + err.Error())
+		return
+	}
+	for _, c := range recipients {
+		if err := c.sendFrame(actionType, frame); err != nil {
+			log.Printf("Error sending to %s: %s", c.ID, err.Error())
+		}
+	}
+}
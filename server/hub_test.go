@@ -0,0 +1,235 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// pipeClient connects one end of a net.Pipe to hub and returns the other end.
+func pipeClient(t *testing.T, hub *Hub) (*Client, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return hub.Connect(server), client
+}
+
+func TestHubRegisterUniqueUsername(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		c, _ := pipeClient(t, hub)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- hub.Register(c, "alice")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	registered := 0
+	for err := range errs {
+		if err == nil {
+			registered++
+		}
+	}
+	if registered != 1 {
+		t.Errorf("expected exactly one registration, got %d", registered)
+	}
+	if users := hub.Room(types.DefaultRoom).Users; len(users) != 1 {
+		t.Errorf("expected one user in %s, got %+v", types.DefaultRoom, users)
+	}
+}
+
+func TestHubBroadcastIsScopedToRoom(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+	alice, _ := pipeClient(t, hub)
+	bob, bobConn := pipeClient(t, hub)
+	carol, carolConn := pipeClient(t, hub)
+	for c, name := range map[*Client]string{alice: "alice", bob: "bob", carol: "carol"} {
+		if err := hub.Register(c, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hub.Join(alice, "dev")
+	hub.Join(bob, "dev")
+
+	message := types.Message{Room: "dev", Value: "hi"}
+	messageB, _ := message.MarshalMsg(nil)
+	hub.Broadcast("dev", alice.ID, types.ActionTypeMessage, messageB)
+
+	bobConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	action, err := protocol.NewReader(bobConn).ReadAction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action.Type != types.ActionTypeMessage {
+		t.Errorf("expected a message, got action type %d", action.Type)
+	}
+
+	carolConn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := protocol.NewReader(carolConn).ReadAction(); err == nil {
+		t.Error("expected carol, who is not in dev, to receive nothing")
+	}
+}
+
+func TestHubUnregisterLeavesRooms(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+	alice, _ := pipeClient(t, hub)
+	if err := hub.Register(alice, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	hub.Join(alice, "dev")
+
+	left := hub.Unregister(alice)
+	if len(left) != 2 {
+		t.Errorf("expected alice to leave two rooms, got %v", left)
+	}
+	if _, ok := hub.FindUser("alice"); ok {
+		t.Error("expected alice to be gone")
+	}
+	for _, room := range hub.Rooms() {
+		if room.Name == "dev" {
+			t.Error("expected the empty dev room to be removed")
+		}
+	}
+}
+
+func TestHubConcurrentConnections(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(100), DefaultQueueConfig)
+	cfg := testConfig(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		c, conn := pipeClient(t, hub)
+		go handleConnection(hub, c, cfg)
+		// Drain everything the hub sends so writes never block.
+		go func() {
+			reader := protocol.NewReader(conn)
+			for {
+				if _, err := reader.ReadFrame(); err != nil {
+					return
+				}
+			}
+		}()
+
+		wg.Add(1)
+		go func(i int, conn net.Conn) {
+			defer wg.Done()
+			registerB, _ := (&types.Register{Username: fmt.Sprintf("user%d", i)}).MarshalMsg(nil)
+			protocol.WriteAction(conn, types.ActionTypeRegister, registerB)
+			for j := 0; j < 10; j++ {
+				message := types.Message{Value: fmt.Sprintf("message %d", j)}
+				messageB, _ := message.MarshalMsg(nil)
+				protocol.WriteAction(conn, types.ActionTypeMessage, messageB)
+			}
+			conn.Close()
+		}(i, conn)
+	}
+	wg.Wait()
+}
+
+// sendFrames sends n numbered messages to c and returns how many were
+// rejected.
+func sendFrames(c *Client, n int) int {
+	rejected := 0
+	for i := 0; i < n; i++ {
+		message := types.Message{Value: fmt.Sprint(i)}
+		messageB, _ := message.MarshalMsg(nil)
+		if err := c.Send(types.ActionTypeMessage, messageB); err != nil {
+			rejected++
+		}
+	}
+	return rejected
+}
+
+func TestSlowConsumerDropNewest(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), QueueConfig{Size: 2, WriteTimeout: time.Minute, Policy: DropNewest})
+	c, _ := pipeClient(t, hub)
+
+	// Nobody reads the pipe: one frame blocks in the writer, two are queued.
+	rejected := sendFrames(c, 10)
+	if rejected < 7 {
+		t.Errorf("expected at least 7 rejected frames, got %d", rejected)
+	}
+	if c.Dropped() != uint64(rejected) || hub.DroppedFrames() != uint64(rejected) {
+		t.Errorf("expected %d dropped frames, got %d for the client and %d for the hub", rejected, c.Dropped(), hub.DroppedFrames())
+	}
+}
+
+func TestSlowConsumerDropOldest(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), QueueConfig{Size: 2, WriteTimeout: time.Minute, Policy: DropOldest})
+	c, conn := pipeClient(t, hub)
+
+	if rejected := sendFrames(c, 10); rejected != 0 {
+		t.Errorf("expected no rejected frames, got %d", rejected)
+	}
+	if c.Dropped() < 7 {
+		t.Errorf("expected at least 7 dropped frames, got %d", c.Dropped())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := protocol.NewReader(conn)
+	last := types.Message{}
+	for i := uint64(0); i < 10-c.Dropped(); i++ {
+		action, err := reader.ReadAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := last.UnmarshalMsg(action.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last.Value != "9" {
+		t.Errorf("expected the newest frame to be kept, got %q", last.Value)
+	}
+}
+
+func TestSlowConsumerDisconnect(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), QueueConfig{Size: 2, WriteTimeout: time.Minute, Policy: Disconnect})
+	c, _ := pipeClient(t, hub)
+
+	sendFrames(c, 10)
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the slow client to be disconnected")
+	}
+}
+
+func TestWriteTimeoutClosesClient(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), QueueConfig{Size: 2, WriteTimeout: 10 * time.Millisecond, Policy: DropNewest})
+	c, _ := pipeClient(t, hub)
+
+	sendFrames(c, 1)
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the write deadline to close the client")
+	}
+}
+
+func TestNewSessionDiscardsPrevious(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+	c, _ := pipeClient(t, hub)
+	if err := hub.Register(c, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		hub.NewSession(c)
+	}
+	if len(hub.sessions) != 1 {
+		t.Errorf("expected a single session, got %d", len(hub.sessions))
+	}
+}
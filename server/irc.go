@@ -0,0 +1,493 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// ircServerName is the name the IRC listener uses as the prefix of its replies
+// and as the host of every user.
+const ircServerName = "tcp-chat"
+
+// maxIRCLine bounds the lines read from IRC clients, including message tags.
+const maxIRCLine = 8192
+
+var errIRCLineTooLong = errors.New("irc line too long")
+
+// ircConn adapts an IRC client connection to the framed stream handled by
+// handleConnection, so IRC users share the Hub with native clients. Commands
+// read from the client become actions, and the actions sent to the client are
+// written as IRC messages. Rooms are IRC channels named after them with a
+// leading #.
+//
+// Only a subset of RFC 1459/2812 is understood: NICK, USER, PASS, JOIN, PART,
+// PRIVMSG, NOTICE, NAMES, PING, PONG, QUIT and the CAP negotiation, which
+// advertises no capabilities. A PASS sent during registration logs in to the
+// account named by NICK.
+type ircConn struct {
+	net.Conn
+	lines *bufio.Reader
+	// read holds the frames of the last command not read yet.
+	read   []byte
+	frames frameBuffer
+
+	// mu guards the fields below and the writes to Conn.
+	mu         sync.Mutex
+	nick       string
+	pass       string
+	user       bool
+	registered bool
+	id         string
+	joined     map[string]bool
+}
+
+func newIRCConn(conn net.Conn) *ircConn {
+	return &ircConn{
+		Conn:   conn,
+		lines:  bufio.NewReaderSize(conn, maxIRCLine),
+		joined: map[string]bool{},
+	}
+}
+
+// ircMessage is a parsed IRC line. Any prefix sent by the client is ignored.
+type ircMessage struct {
+	command string
+	params  []string
+}
+
+func parseIRC(line string) ircMessage {
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	message := ircMessage{}
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, ":") {
+			message.params = append(message.params, line[1:])
+			break
+		}
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		if param == "" {
+			continue
+		}
+		if message.command == "" {
+			message.command = strings.ToUpper(param)
+			continue
+		}
+		message.params = append(message.params, param)
+	}
+	return message
+}
+
+// Read returns the actions translated from the commands of the client.
+// Commands answered without the Hub, like PING, are replied to directly.
+func (c *ircConn) Read(p []byte) (int, error) {
+	for len(c.read) == 0 {
+		line, err := c.lines.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return 0, errIRCLineTooLong
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := c.handle(parseIRC(strings.TrimRight(string(line), "\r\n"))); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.read)
+	c.read = c.read[n:]
+	return n, nil
+}
+
+// handle translates one command, queueing its actions in c.read. It returns
+// io.EOF once the client quits.
+func (c *ircConn) handle(message ircMessage) error {
+	params := message.params
+	switch message.command {
+	case "":
+		return nil
+	case "CAP":
+		if len(params) > 0 && strings.ToUpper(params[0]) == "LS" {
+			return c.reply("CAP", "*", "LS", "")
+		}
+		return nil
+	case "PASS":
+		if len(params) < 1 {
+			return c.numeric("461", "PASS", "Not enough parameters")
+		}
+		c.mu.Lock()
+		c.pass = params[0]
+		c.mu.Unlock()
+		return nil
+	case "NICK":
+		if len(params) < 1 {
+			return c.numeric("431", "No nickname given")
+		}
+		c.mu.Lock()
+		registered := c.registered
+		if !registered {
+			c.nick = params[0]
+		}
+		c.mu.Unlock()
+		if registered {
+			return c.queue(types.ActionTypeRename, params[0], &types.User{Username: params[0]})
+		}
+		return c.register()
+	case "USER":
+		if len(params) < 4 {
+			return c.numeric("461", "USER", "Not enough parameters")
+		}
+		c.mu.Lock()
+		c.user = true
+		c.mu.Unlock()
+		return c.register()
+	case "JOIN", "PART", "NAMES":
+		if len(params) < 1 {
+			if message.command == "NAMES" {
+				return c.names()
+			}
+			return c.numeric("461", message.command, "Not enough parameters")
+		}
+		actionType := map[string]types.ActionType{
+			"JOIN":  types.ActionTypeJoinRoom,
+			"PART":  types.ActionTypeLeaveRoom,
+			"NAMES": types.ActionTypeGetUsers,
+		}[message.command]
+		for _, channel := range strings.Split(params[0], ",") {
+			if err := c.queue(actionType, channel, &types.Room{Name: strings.TrimPrefix(channel, "#")}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "PRIVMSG", "NOTICE":
+		if len(params) < 1 {
+			return c.numeric("411", "No recipient given ("+message.command+")")
+		}
+		if len(params) < 2 || params[1] == "" {
+			return c.numeric("412", "No text to send")
+		}
+		value := params[1]
+		if action, ok := strings.CutPrefix(value, "\x01ACTION "); ok {
+			value = "/me " + strings.TrimSuffix(action, "\x01")
+		}
+		for _, target := range strings.Split(params[0], ",") {
+			var err error
+			if room, ok := strings.CutPrefix(target, "#"); ok {
+				err = c.queue(types.ActionTypeMessage, target, &types.Message{Room: room, Value: value})
+			} else {
+				err = c.queue(types.ActionTypeDirect, target, &types.DirectMessage{To: target, Value: value})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "PING":
+		if len(params) < 1 {
+			return c.numeric("409", "No origin specified")
+		}
+		return c.reply("PONG", ircServerName, params[0])
+	case "PONG":
+		return c.queue(types.ActionTypePong, "", nil)
+	case "QUIT":
+		c.mu.Lock()
+		c.writeLocked(ircLine("", "ERROR", "Closing link"))
+		c.mu.Unlock()
+		return io.EOF
+	default:
+		return c.numeric("421", message.command, "Unknown command")
+	}
+}
+
+// register requests the registration of the client once it sent both NICK
+// and USER, logging in when it sent PASS.
+func (c *ircConn) register() error {
+	c.mu.Lock()
+	if c.registered || c.nick == "" || !c.user {
+		c.mu.Unlock()
+		return nil
+	}
+	nick, pass := c.nick, c.pass
+	c.mu.Unlock()
+	if pass != "" {
+		return c.queue(types.ActionTypeLogin, nick, &types.Credentials{Username: nick, Password: pass})
+	}
+	return c.queue(types.ActionTypeRegister, nick, &types.Register{Username: nick})
+}
+
+// names requests the users of every joined channel.
+func (c *ircConn) names() error {
+	c.mu.Lock()
+	rooms := make([]string, 0, len(c.joined))
+	for room := range c.joined {
+		rooms = append(rooms, room)
+	}
+	c.mu.Unlock()
+	for _, room := range rooms {
+		if err := c.queue(types.ActionTypeGetUsers, "#"+room, &types.Room{Name: room}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queue appends an action to c.read. The ID of the action is the target of the
+// command, which errors echo back to fill in the IRC error reply.
+func (c *ircConn) queue(actionType types.ActionType, id string, payload msgp.Marshaler) error {
+	action := types.Action{Type: actionType, ID: id}
+	if payload != nil {
+		data, err := payload.MarshalMsg(nil)
+		if err != nil {
+			return err
+		}
+		action.Data = data
+	}
+	actionB, err := action.MarshalMsg(nil)
+	if err != nil {
+		return err
+	}
+	c.read, err = protocol.AppendFrame(c.read, actionB)
+	return err
+}
+
+// Write sends every frame of p to the client as IRC messages.
+func (c *ircConn) Write(p []byte) (int, error) {
+	return c.frames.write(p, func(frame []byte) error {
+		action := types.Action{}
+		if _, err := action.UnmarshalMsg(frame); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		lines, err := c.translateLocked(action)
+		if err != nil {
+			return err
+		}
+		return c.writeLocked(lines...)
+	})
+}
+
+// translateLocked returns the IRC messages for an action sent to the client.
+// Actions without an IRC equivalent, like reactions, are dropped.
+func (c *ircConn) translateLocked(action types.Action) ([]string, error) {
+	switch action.Type {
+	case types.ActionTypeSession:
+		session := types.Session{}
+		if _, err := session.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		c.registered = true
+		c.id = session.UserID
+		return []string{
+			c.numericLine("001", "Welcome to "+ircServerName+" "+c.nick),
+			c.numericLine("422", "MOTD File is missing"),
+		}, nil
+	case types.ActionTypeGetUsers:
+		room := types.Room{}
+		if _, err := room.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		lines := []string{}
+		names := make([]string, 0, len(room.Users))
+		for _, user := range room.Users {
+			if user.ID == c.id && user.Username != c.nick {
+				// The username was set by a client certificate.
+				lines = append(lines, ircLine(ircPrefix(c.nick), "NICK", user.Username))
+				c.nick = user.Username
+			}
+			names = append(names, user.Username)
+		}
+		if !c.joined[room.Name] {
+			c.joined[room.Name] = true
+			lines = append(lines, ircLine(ircPrefix(c.nick), "JOIN", "#"+room.Name))
+		}
+		return append(lines,
+			c.numericLine("353", "=", "#"+room.Name, strings.Join(names, " ")),
+			c.numericLine("366", "#"+room.Name, "End of NAMES list"),
+		), nil
+	case types.ActionTypeLeaveRoom:
+		room := types.Room{}
+		if _, err := room.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		delete(c.joined, room.Name)
+		return []string{ircLine(ircPrefix(c.nick), "PART", "#"+room.Name)}, nil
+	case types.ActionTypePresence:
+		presence := types.Presence{}
+		if _, err := presence.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		prefix := ircPrefix(presence.User.Username)
+		switch presence.Type {
+		case types.PresenceJoined:
+			return []string{ircLine(prefix, "JOIN", "#"+presence.Room)}, nil
+		case types.PresenceLeft:
+			return []string{ircLine(prefix, "PART", "#"+presence.Room)}, nil
+		case types.PresenceRenamed:
+			if presence.User.ID == c.id {
+				c.nick = presence.User.Username
+			}
+			return []string{ircLine(ircPrefix(presence.Previous), "NICK", presence.User.Username)}, nil
+		}
+	case types.ActionTypeMessage:
+		message := types.Message{}
+		if _, err := message.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		return ircPrivmsg(message.Username, "#"+message.Room, message.Value), nil
+	case types.ActionTypeDirect:
+		message := types.DirectMessage{}
+		if _, err := message.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		return ircPrivmsg(message.Username, c.nick, message.Value), nil
+	case types.ActionTypePing:
+		return []string{ircLine("", "PING", ircServerName)}, nil
+	case types.ActionTypeError:
+		errMsg := types.ErrorMessage{}
+		if _, err := errMsg.UnmarshalMsg(action.Data); err != nil {
+			return nil, err
+		}
+		return []string{c.errorLine(errMsg)}, nil
+	}
+	return nil, nil
+}
+
+// errorLine returns the IRC error reply for errMsg. Its ID is the target of the
+// command that failed.
+func (c *ircConn) errorLine(errMsg types.ErrorMessage) string {
+	target := errMsg.ID
+	switch errMsg.Code {
+	case types.ErrorCodeUsernameTaken:
+		return c.numericLine("433", target, "Nickname is already in use")
+	case types.ErrorCodeInvalidUsername:
+		return c.numericLine("432", target, errMsg.Value)
+	case types.ErrorCodeRecipientOffline:
+		return c.numericLine("401", target, "No such nick/channel")
+	case types.ErrorCodeInvalidRoom:
+		return c.numericLine("403", target, "No such channel")
+	case types.ErrorCodeNotRoomMember:
+		return c.numericLine("442", target, "You're not on that channel")
+	case types.ErrorCodeNotRegistered:
+		return c.numericLine("451", "You have not registered")
+	case types.ErrorCodeAlreadyRegistered:
+		return c.numericLine("462", "You may not reregister")
+	case types.ErrorCodeAuthRequired, types.ErrorCodeBadCredentials:
+		return c.numericLine("464", errMsg.Value)
+	case types.ErrorCodeKicked:
+		return ircLine("", "ERROR", "Closing link: "+errMsg.Value)
+	}
+	return ircLine(ircServerName, "NOTICE", c.target(), errMsg.Error())
+}
+
+// target is the nick replies are addressed to, or * before the client is
+// registered.
+func (c *ircConn) target() string {
+	if !c.registered {
+		return "*"
+	}
+	return c.nick
+}
+
+func (c *ircConn) numericLine(code string, params ...string) string {
+	return ircLine(ircServerName, code, append([]string{c.target()}, params...)...)
+}
+
+// numeric replies to the client with a numeric reply.
+func (c *ircConn) numeric(code string, params ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeLocked(c.numericLine(code, params...))
+}
+
+// reply sends the client a message from the server.
+func (c *ircConn) reply(command string, params ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeLocked(ircLine(ircServerName, command, params...))
+}
+
+func (c *ircConn) writeLocked(lines ...string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	_, err := io.WriteString(c.Conn, b.String())
+	return err
+}
+
+// ConnectionState returns the TLS state of the connection, so client
+// certificates work as they do for native clients.
+func (c *ircConn) ConnectionState() tls.ConnectionState {
+	if tlsConn, ok := c.Conn.(*tls.Conn); ok {
+		return tlsConn.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
+// ircPrefix returns the prefix of the messages sent by username.
+func ircPrefix(username string) string {
+	return username + "!" + username + "@" + ircServerName
+}
+
+// ircLine formats an IRC message. The last parameter is always sent as a
+// trailing parameter so it may contain spaces.
+func ircLine(prefix, command string, params ...string) string {
+	var b strings.Builder
+	if prefix != "" {
+		b.WriteString(":" + prefix + " ")
+	}
+	b.WriteString(command)
+	for i, param := range params {
+		if i == len(params)-1 {
+			b.WriteString(" :")
+		} else {
+			b.WriteString(" ")
+		}
+		b.WriteString(param)
+	}
+	return b.String()
+}
+
+// ircPrivmsg returns the PRIVMSG lines of a message, one per line of value.
+// CR, which would end the IRC line, splits lines too, and NUL, which IRC
+// forbids, is dropped. Emotes sent with /me become CTCP ACTIONs.
+func ircPrivmsg(username, target, value string) []string {
+	lines := []string{}
+	value = strings.ReplaceAll(value, "\x00", "")
+	for _, text := range strings.FieldsFunc(value, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if action, ok := strings.CutPrefix(text, "/me "); ok {
+			text = "\x01ACTION " + action + "\x01"
+		}
+		lines = append(lines, ircLine(ircPrefix(username), "PRIVMSG", target, text))
+	}
+	return lines
+}
+
+// serveIRC accepts IRC clients on listen and serves them from hub.
+func serveIRC(listen net.Listener, hub *Hub, cfg config) error {
+	for {
+		conn, err := listen.Accept()
+		if err != nil {
+			return err
+		}
+		go handleConnection(hub, hub.Connect(newIRCConn(conn)), cfg)
+	}
+}
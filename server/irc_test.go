@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// startIRC serves one hub over TCP and IRC, returning both addresses.
+func startIRC(t *testing.T) (string, string) {
+	t.Helper()
+	cfg := testConfig(t)
+	hub, err := newHub(NewMemoryHistory(10), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen, err := net.Listen(network, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listen.Close() })
+	go serveIRC(listen, hub, cfg)
+	return listenTCP(t, hub, cfg), listen.Addr().String()
+}
+
+type ircClient struct {
+	t     *testing.T
+	conn  net.Conn
+	lines *bufio.Reader
+}
+
+func dialIRC(t *testing.T, address string) *ircClient {
+	t.Helper()
+	conn := dial(t, address)
+	return &ircClient{t: t, conn: conn, lines: bufio.NewReader(conn)}
+}
+
+func (c *ircClient) send(line string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readUntil reads lines until one contains text and returns it.
+func (c *ircClient) readUntil(text string) string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		line, err := c.lines.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("waiting for %q: %v", text, err)
+		}
+		if strings.Contains(line, text) {
+			return strings.TrimRight(line, "\r\n")
+		}
+	}
+}
+
+func (c *ircClient) register(nick string) {
+	c.t.Helper()
+	c.send("NICK " + nick)
+	c.send("USER " + nick + " 0 * :" + nick)
+	c.readUntil(" 001 " + nick + " ")
+	c.readUntil(" 366 " + nick + " #" + types.DefaultRoom + " ")
+}
+
+func TestParseIRC(t *testing.T) {
+	message := parseIRC("@time=now :nick!user@host PRIVMSG  #general :hello  there")
+	if message.command != "PRIVMSG" || len(message.params) != 2 || message.params[0] != "#general" || message.params[1] != "hello  there" {
+		t.Errorf("unexpected parse %+v", message)
+	}
+	if message := parseIRC("ping tcp-chat"); message.command != "PING" || len(message.params) != 1 {
+		t.Errorf("unexpected parse %+v", message)
+	}
+}
+
+func TestIRCPrivmsgEscapesLines(t *testing.T) {
+	lines := ircPrivmsg("alice", "#general", "hi\r:mallory PRIVMSG #general :fake\r\n\x00/me waves\n")
+	expected := []string{
+		":alice!alice@tcp-chat PRIVMSG #general :hi",
+		":alice!alice@tcp-chat PRIVMSG #general ::mallory PRIVMSG #general :fake",
+		":alice!alice@tcp-chat PRIVMSG #general :\x01ACTION waves\x01",
+	}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
+
+func TestIRCSharesHub(t *testing.T) {
+	address, ircAddress := startIRC(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+
+	bob := dialIRC(t, ircAddress)
+	bob.register("bob")
+	if presence := readPresence(t, alice, aliceReader); presence.User.Username != "bob" {
+		t.Errorf("expected bob to join, got %+v", presence)
+	}
+
+	bob.send("NAMES #" + types.DefaultRoom)
+	if line := bob.readUntil(" 353 "); !strings.HasSuffix(line, ":alice bob") && !strings.HasSuffix(line, ":bob alice") {
+		t.Errorf("expected alice and bob in the names, got %q", line)
+	}
+
+	bob.send("PRIVMSG #" + types.DefaultRoom + " :hello from irc")
+	message := types.Message{}
+	message.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeMessage).Data)
+	if message.Username != "bob" || message.Value != "hello from irc" {
+		t.Errorf("expected the message of bob, got %+v", message)
+	}
+
+	messageB, _ := (&types.Message{Value: "/me waves"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	if line := bob.readUntil("PRIVMSG"); line != ":alice!alice@tcp-chat PRIVMSG #general :\x01ACTION waves\x01" {
+		t.Errorf("unexpected message %q", line)
+	}
+
+	bob.send("PRIVMSG alice :psst")
+	direct := types.DirectMessage{}
+	direct.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeDirect).Data)
+	if direct.Username != "bob" || direct.Value != "psst" {
+		t.Errorf("expected the direct message of bob, got %+v", direct)
+	}
+
+	bob.send("PRIVMSG carol :hi")
+	bob.readUntil(" 401 bob carol ")
+
+	bob.send("PING :12345")
+	if line := bob.readUntil("PONG"); line != ":tcp-chat PONG tcp-chat :12345" {
+		t.Errorf("unexpected pong %q", line)
+	}
+
+	bob.send("QUIT :bye")
+	bob.readUntil("ERROR")
+	if presence := readPresence(t, alice, aliceReader); presence.Type != types.PresenceLeft || presence.User.Username != "bob" {
+		t.Errorf("expected bob to leave, got %+v", presence)
+	}
+}
+
+func TestIRCJoinPartAndNick(t *testing.T) {
+	_, ircAddress := startIRC(t)
+	alice := dialIRC(t, ircAddress)
+	alice.register("alice")
+
+	bob := dialIRC(t, ircAddress)
+	bob.send("NICK alice")
+	bob.send("USER bob 0 * :Bob")
+	bob.readUntil(" 433 * alice ")
+	bob.register("bob")
+
+	alice.send("JOIN #dev")
+	alice.readUntil(":alice!alice@tcp-chat JOIN :#dev")
+	alice.readUntil(" 366 alice #dev ")
+	bob.send("JOIN #dev")
+	bob.readUntil(" 366 bob #dev ")
+	alice.readUntil(":bob!bob@tcp-chat JOIN :#dev")
+
+	bob.send("NICK robert")
+	bob.readUntil(":bob!bob@tcp-chat NICK :robert")
+	alice.readUntil(":bob!bob@tcp-chat NICK :robert")
+
+	bob.send("PART #dev")
+	bob.readUntil(":robert!robert@tcp-chat PART :#dev")
+	alice.readUntil(":robert!robert@tcp-chat PART :#dev")
+
+	bob.send("PRIVMSG #dev :still here?")
+	bob.readUntil(" 442 robert #dev ")
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// metricsPath is the endpoint Prometheus scrapes.
+const metricsPath = "/metrics"
+
+// metrics instruments a Hub. Each Hub has its own registry, so tests can run
+// several of them.
+type metrics struct {
+	registry         *prometheus.Registry
+	actionsIn        *prometheus.CounterVec
+	actionsOut       *prometheus.CounterVec
+	bytesIn          prometheus.Counter
+	bytesOut         prometheus.Counter
+	unmarshalErrors  prometheus.Counter
+	writeErrors      prometheus.Counter
+	broadcastLatency *prometheus.HistogramVec
+}
+
+func newMetrics(h *Hub) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		actionsIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tcp_chat_actions_received_total",
+			Help: "Actions read from clients, by action type.",
+		}, []string{"type"}),
+		actionsOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tcp_chat_actions_sent_total",
+			Help: "Actions queued for clients, by action type.",
+		}, []string{"type"}),
+		bytesIn: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tcp_chat_received_bytes_total",
+			Help: "Bytes of the frames read from clients.",
+		}),
+		bytesOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tcp_chat_sent_bytes_total",
+			Help: "Bytes of the frames written to clients.",
+		}),
+		unmarshalErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tcp_chat_unmarshal_errors_total",
+			Help: "Actions or payloads from clients that could not be unmarshalled.",
+		}),
+		writeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tcp_chat_write_errors_total",
+			Help: "Failed writes to client connections.",
+		}),
+		broadcastLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tcp_chat_broadcast_duration_seconds",
+			Help:    "Time to queue a broadcast for every recipient, by action type.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"type"}),
+	}
+	m.registry.MustRegister(
+		m.actionsIn, m.actionsOut, m.bytesIn, m.bytesOut,
+		m.unmarshalErrors, m.writeErrors, m.broadcastLatency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tcp_chat_connected_clients",
+			Help: "Open client connections, registered or not.",
+		}, func() float64 { return float64(h.connectedClients()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tcp_chat_registered_users",
+			Help: "Connected clients that registered a username.",
+		}, func() float64 { return float64(len(h.Users())) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "tcp_chat_dropped_frames_total",
+			Help: "Frames dropped because the write queue of a client was full.",
+		}, func() float64 { return float64(h.DroppedFrames()) }),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observeBroadcast records the latency of a broadcast that started at start.
+func (m *metrics) observeBroadcast(actionType types.ActionType, start time.Time) {
+	m.broadcastLatency.WithLabelValues(actionType.String()).Observe(time.Since(start).Seconds())
+}
+
+// metricsHandler serves the metrics of hub on metricsPath.
+func metricsHandler(hub *Hub) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(hub.metrics.registry, promhttp.HandlerOpts{}))
+	return mux
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func TestMetrics(t *testing.T) {
+	cfg := testConfig(t)
+	hub, err := newHub(NewMemoryHistory(10), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listenTCP(t, hub, cfg)
+	server := httptest.NewServer(metricsHandler(hub))
+	t.Cleanup(server.Close)
+
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	readPresence(t, alice, aliceReader)
+	messageB, _ := (&types.Message{Value: "hello"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeMessage, messageB)
+	readUntil(t, alice, aliceReader, types.ActionTypeMessage)
+	protocol.WriteAction(bob, types.ActionTypeJoinRoom, []byte{0xc1})
+	readUntil(t, bob, bobReader, types.ActionTypeError)
+
+	resp, err := http.Get(server.URL + metricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	bodyB, _ := io.ReadAll(resp.Body)
+	body := string(bodyB)
+	for _, series := range []string{
+		"tcp_chat_connected_clients 2",
+		"tcp_chat_registered_users 2",
+		`tcp_chat_actions_received_total{type="message"} 1`,
+		`tcp_chat_actions_received_total{type="register"} 2`,
+		`tcp_chat_actions_sent_total{type="message"} 1`,
+		"tcp_chat_unmarshal_errors_total 1",
+		"tcp_chat_dropped_frames_total 0",
+		`tcp_chat_broadcast_duration_seconds_count{type="message"} 1`,
+	} {
+		if !strings.Contains(body, series+"\n") {
+			t.Errorf("expected %q in the metrics", series)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// botQueueSize is the number of events queued for a bot before new events are
+// dropped.
+const botQueueSize = 64
+
+// Plugin is a bot running inside the server. Its handlers are called from a
+// goroutine dedicated to the plugin, one event at a time, and can post
+// messages through bot. Messages posted by bots are not passed to plugins.
+type Plugin interface {
+	// HandleMessage is called for every message sent by a user to a room.
+	HandleMessage(bot *Bot, message types.Message)
+	// HandlePresence is called for every user joining or leaving a room, or
+	// changing their name.
+	HandlePresence(bot *Bot, presence types.Presence)
+}
+
+// PluginFactory creates a new instance of a plugin.
+type PluginFactory func() Plugin
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = map[string]PluginFactory{}
+)
+
+// RegisterPlugin makes a plugin available to the --plugin flag under name. It
+// panics if name is registered twice.
+func RegisterPlugin(name string, factory PluginFactory) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	if _, ok := plugins[name]; ok {
+		panic("server: plugin " + name + " registered twice")
+	}
+	plugins[name] = factory
+}
+
+// Plugins returns the names of the registered plugins, sorted.
+func Plugins() []string {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	names := []string{}
+	for name := range plugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewPlugin creates the plugin registered under name.
+func NewPlugin(name string) (Plugin, error) {
+	pluginsMu.RLock()
+	factory, ok := plugins[name]
+	pluginsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin %q, available plugins: %s", name, strings.Join(Plugins(), ", "))
+	}
+	return factory(), nil
+}
+
+// Bot is the virtual user a plugin posts messages as. It is not a member of
+// any room but can post to all of them.
+type Bot struct {
+	types.User
+	hub    *Hub
+	plugin Plugin
+	events chan any
+}
+
+// AddBot runs plugin as a bot named username. Bots should be added before
+// clients connect, as their names are only reserved from then on. A bot with a
+// nil plugin only posts, like the service account of the API.
+func (h *Hub) AddBot(username string, plugin Plugin) *Bot {
+	bot := &Bot{
+		User:   types.User{ID: "bot-" + username, Username: username},
+		hub:    h,
+		plugin: plugin,
+		events: make(chan any, botQueueSize),
+	}
+	h.mu.Lock()
+	h.bots = append(h.bots, bot)
+	h.mu.Unlock()
+	if plugin != nil {
+		go bot.run()
+	}
+	return bot
+}
+
+// Post sends a message to room as the bot.
+func (b *Bot) Post(room, value string) types.Message {
+	return postMessage(b.hub, types.Message{
+		UserID:    b.ID,
+		Username:  b.Username,
+		Room:      room,
+		Value:     value,
+		Timestamp: time.Now().UnixMilli(),
+	}, "")
+}
+
+func (b *Bot) run() {
+	for event := range b.events {
+		switch event := event.(type) {
+		case types.Message:
+			b.plugin.HandleMessage(b, event)
+		case types.Presence:
+			b.plugin.HandlePresence(b, event)
+		}
+	}
+}
+
+// notifyBots queues event, a types.Message or types.Presence, for every bot.
+// Events are dropped for bots that fall behind. Messages of bots are not
+// queued, so bots cannot trigger each other endlessly.
+func (h *Hub) notifyBots(event any) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if message, ok := event.(types.Message); ok && h.isBotLocked(message.UserID) {
+		return
+	}
+	for _, bot := range h.bots {
+		if bot.plugin == nil {
+			continue
+		}
+		select {
+		case bot.events <- event:
+		default:
+			log.Print("Error notifying bot " + bot.Username + ": event queue is full")
+		}
+	}
+}
+
+// isBotLocked reports whether id is the user ID of a bot.
+func (h *Hub) isBotLocked(id string) bool {
+	return slices.ContainsFunc(h.bots, func(b *Bot) bool { return b.ID == id })
+}
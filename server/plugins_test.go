@@ -0,0 +1,101 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// recorderPlugin forwards the events it receives to a channel.
+type recorderPlugin chan any
+
+func (r recorderPlugin) HandleMessage(_ *Bot, message types.Message) {
+	r <- message
+}
+
+func (r recorderPlugin) HandlePresence(_ *Bot, presence types.Presence) {
+	r <- presence
+}
+
+func nextEvent(t *testing.T, events <-chan any) any {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return nil
+	}
+}
+
+func TestBotEvents(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+	events := make(recorderPlugin, 10)
+	bot := hub.AddBot("recorder", events)
+
+	alice := types.User{ID: "1", Username: "alice"}
+	sendPresence(hub, types.Presence{Type: types.PresenceJoined, User: alice, Room: types.DefaultRoom})
+	bot.Post(types.DefaultRoom, "not delivered to bots")
+	postMessage(hub, types.Message{UserID: alice.ID, Username: alice.Username, Room: types.DefaultRoom, Value: "hi"}, "")
+
+	if presence, ok := nextEvent(t, events).(types.Presence); !ok || presence.User.Username != "alice" {
+		t.Errorf("expected alice to join, got %+v", presence)
+	}
+	if message, ok := nextEvent(t, events).(types.Message); !ok || message.Value != "hi" {
+		t.Errorf("expected the message of alice, got %+v", message)
+	}
+}
+
+func TestBotUsernameTaken(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+	hub.AddBot("echo", echoPlugin{})
+	c, _ := pipeClient(t, hub)
+	if err := hub.Register(c, "Echo"); err == nil {
+		t.Error("expected the name of a bot to be taken")
+	}
+}
+
+func TestEchoPlugin(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.plugins = []string{"echo"}
+	conn := dial(t, startServerWith(t, cfg))
+	reader := protocol.NewReader(conn)
+	register(t, conn, reader, "alice")
+
+	messageB, _ := (&types.Message{Value: "!echo hello"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeMessage, messageB)
+	message := types.Message{}
+	message.UnmarshalMsg(readUntil(t, conn, reader, types.ActionTypeMessage).Data)
+	if message.Username != "echo" || message.Value != "hello" || message.ID == 0 {
+		t.Errorf("expected echo to say hello, got %+v", message)
+	}
+}
+
+func TestDicePlugin(t *testing.T) {
+	hub := NewHub(NewMemoryHistory(10), DefaultQueueConfig)
+	bot := hub.AddBot("dice", &dicePlugin{roll: func(n int) int { return n - 1 }})
+
+	for value, expected := range map[string]string{
+		"!roll 3d6": "alice rolled 3d6: 18 (6 + 6 + 6)",
+		"!roll":     "alice rolled 1d6: 6 (6)",
+		"!roll 0d6": "number of dice must be between 1 and 20",
+		"!roll six": "invalid dice \"six\", expected NdM like 2d6",
+	} {
+		bot.plugin.HandleMessage(bot, types.Message{Username: "alice", Room: types.DefaultRoom, Value: value})
+		page, err := hub.history.Before(types.DefaultRoom, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || page[0].Message.Value != expected || page[0].Message.Username != "dice" {
+			t.Errorf("expected %q to answer %q, got %+v", value, expected, page)
+		}
+	}
+}
+
+func TestNewPluginUnknown(t *testing.T) {
+	if _, err := NewPlugin("nope"); err == nil {
+		t.Error("expected an unknown plugin to fail")
+	}
+}
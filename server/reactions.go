@@ -0,0 +1,52 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+const (
+	// maxReactionLength is the longest reaction accepted, in runes, enough
+	// for emoji sequences and short words.
+	maxReactionLength = 16
+	// maxReactions is the number of distinct reactions a message can have.
+	maxReactions = 20
+)
+
+var errTooManyReactions = types.ErrorMessage{Code: types.ErrorCodeMalformedAction, Value: "message has too many reactions"}
+
+// validReaction reports whether emoji can be used as a reaction.
+func validReaction(emoji string) bool {
+	n := utf8.RuneCountInString(emoji)
+	return n > 0 && n <= maxReactionLength && !strings.ContainsAny(emoji, " \t\r\n")
+}
+
+// toggleReaction adds the reaction emoji of userID to message, or removes it
+// when userID already reacted with it. The reactions are copied before being
+// changed, as the stored message may be read concurrently.
+func toggleReaction(message *types.Message, userID, emoji string) error {
+	message.Reactions = slices.Clone(message.Reactions)
+	i := slices.IndexFunc(message.Reactions, func(r types.ReactionCount) bool { return r.Emoji == emoji })
+	if i < 0 {
+		if len(message.Reactions) >= maxReactions {
+			return errTooManyReactions
+		}
+		message.Reactions = append(message.Reactions, types.ReactionCount{Emoji: emoji})
+		i = len(message.Reactions) - 1
+	}
+	reaction := &message.Reactions[i]
+	reaction.UserIDs = slices.Clone(reaction.UserIDs)
+	if j := slices.Index(reaction.UserIDs, userID); j >= 0 {
+		reaction.UserIDs = slices.Delete(reaction.UserIDs, j, j+1)
+	} else {
+		reaction.UserIDs = append(reaction.UserIDs, userID)
+	}
+	reaction.Count = len(reaction.UserIDs)
+	if reaction.Count == 0 {
+		message.Reactions = slices.Delete(message.Reactions, i, i+1)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func TestToggleReaction(t *testing.T) {
+	message := types.Message{}
+	for _, userID := range []string{"alice", "bob", "alice"} {
+		if err := toggleReaction(&message, userID, "👍"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(message.Reactions) != 1 || message.Reactions[0].Count != 1 || message.Reactions[0].UserIDs[0] != "bob" {
+		t.Errorf("expected only bob to react, got %+v", message.Reactions)
+	}
+
+	if err := toggleReaction(&message, "bob", "👍"); err != nil {
+		t.Fatal(err)
+	}
+	if len(message.Reactions) != 0 {
+		t.Errorf("expected the reaction to be removed, got %+v", message.Reactions)
+	}
+}
+
+func TestTooManyReactions(t *testing.T) {
+	message := types.Message{}
+	for i := 0; i < maxReactions; i++ {
+		if err := toggleReaction(&message, "alice", fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := toggleReaction(&message, "alice", "x"); !errors.Is(err, errTooManyReactions) {
+		t.Errorf("expected errTooManyReactions, got %v", err)
+	}
+	if err := toggleReaction(&message, "bob", "0"); err != nil {
+		t.Errorf("expected reacting with an existing reaction to succeed, got %v", err)
+	}
+}
+
+func TestValidReaction(t *testing.T) {
+	for emoji, valid := range map[string]bool{
+		"👍":                  true,
+		"lgtm":               true,
+		"":                   false,
+		"two words":          false,
+		"waytoolongreaction": false,
+	} {
+		if validReaction(emoji) != valid {
+			t.Errorf("expected validReaction(%q) to be %v", emoji, valid)
+		}
+	}
+}
+
+func TestReactionBroadcast(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+
+	messageB, _ := (&types.Message{Value: "ship it?", Nonce: "1"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	ack := types.Ack{}
+	ack.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeAck).Data)
+
+	reactionB, _ := (&types.Reaction{MessageID: ack.ID, Emoji: "🚀"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeReact, reactionB)
+	message := types.Message{}
+	if _, err := message.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeReact).Data); err != nil {
+		t.Fatal(err)
+	}
+	if message.ID != ack.ID || len(message.Reactions) != 1 || message.Reactions[0].Count != 1 || message.Reactions[0].Emoji != "🚀" {
+		t.Errorf("expected one 🚀 on message %d, got %+v", ack.ID, message)
+	}
+
+	reactionB, _ = (&types.Reaction{MessageID: ack.ID + 1, Emoji: "🚀"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeReact, reactionB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeMessageNotFound {
+		t.Errorf("expected reacting to an unknown message to fail, got %+v", errMsg)
+	}
+}
@@ -1,146 +1,962 @@
 package server
 
 import (
-	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
 	"net"
-	"sync"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
 
-	"github.com/google/uuid"
+	"github.com/tashima42/tcp-chat/protocol"
 	"github.com/tashima42/tcp-chat/types"
 	"github.com/urfave/cli/v2"
 )
 
 const (
-	protocol = "tcp"
+	network = "tcp"
+	// maxHistoryPage is the largest number of messages sent in one history
+	// page.
+	maxHistoryPage = 100
+	// typingInterval is the shortest interval between two typing
+	// notifications of a client to the same room. Notifications arriving
+	// faster are dropped.
+	typingInterval = time.Second
 )
 
+type config struct {
+	address       string
+	historySize   int
+	historyFile   string
+	historyReplay int
+	tlsCert       string
+	tlsKey        string
+	tlsCA         string
+	usernames     usernameRules
+	queue         QueueConfig
+	heartbeat     time.Duration
+	idleTimeout   time.Duration
+	sessionTTL    time.Duration
+	accounts      *Accounts
+	authRequired  bool
+	// moderators holds the lowercase usernames that may edit and delete any
+	// message once authenticated.
+	moderators map[string]bool
+	plugins    []string
+	// websocketAddress enables the WebSocket gateway when set.
+	websocketAddress string
+	websocketOrigins []string
+	// ircAddress enables the IRC listener when set.
+	ircAddress string
+	// apiAddress enables the HTTP API when set. Its requests must carry
+	// apiToken and its messages are posted as apiUsername.
+	apiAddress  string
+	apiToken    string
+	apiUsername string
+	// webhooks receive the messages sent by clients, when configured.
+	webhooks *Webhooks
+	// metricsAddress enables the Prometheus endpoint when set.
+	metricsAddress string
+}
+
 func Command() *cli.Command {
 	return &cli.Command{
 		Name:  "server",
 		Usage: "tcp chat server",
 		Flags: []cli.Flag{
+			// address is checked by serverCommand rather than marked as
+			// required, so the account subcommands work without it.
+			&cli.StringFlag{
+				Name:    "address",
+				Usage:   "address to bind the server to",
+				Aliases: []string{"a"},
+			},
+			&cli.IntFlag{
+				Name:  "history-size",
+				Usage: "number of messages kept in memory for history replay",
+				Value: 1000,
+			},
+			&cli.StringFlag{
+				Name:  "history-file",
+				Usage: "append messages to this file so history survives restarts",
+			},
+			&cli.IntFlag{
+				Name:  "history-replay",
+				Usage: "number of messages replayed to users joining a room",
+				Value: 50,
+			},
+			&cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "PEM certificate file, enables TLS together with --tls-key",
+			},
+			&cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "PEM private key file of --tls-cert",
+			},
+			&cli.StringFlag{
+				Name:  "tls-ca",
+				Usage: "PEM CA bundle used to verify client certificates, enables mutual TLS",
+			},
+			&cli.IntFlag{
+				Name:  "username-min-length",
+				Usage: "minimum number of characters in a username",
+				Value: 1,
+			},
+			&cli.IntFlag{
+				Name:  "username-max-length",
+				Usage: "maximum number of characters in a username",
+				Value: 20,
+			},
+			&cli.StringFlag{
+				Name:  "username-pattern",
+				Usage: "regular expression usernames must match",
+				Value: defaultUsernamePattern,
+			},
+			&cli.StringSliceFlag{
+				Name:  "reserved-username",
+				Usage: "username nobody may register, can be repeated",
+				Value: cli.NewStringSlice(defaultReservedUsernames...),
+			},
+			&cli.IntFlag{
+				Name:  "write-queue-size",
+				Usage: "number of frames queued for each client before the slow consumer policy applies",
+				Value: DefaultQueueConfig.Size,
+			},
+			&cli.DurationFlag{
+				Name:  "write-timeout",
+				Usage: "deadline for writing a single frame to a client",
+				Value: DefaultQueueConfig.WriteTimeout,
+			},
+			&cli.StringFlag{
+				Name:  "slow-consumer-policy",
+				Usage: "what to do when a client queue is full: drop-oldest, drop-newest or disconnect",
+				Value: "drop-oldest",
+			},
+			&cli.DurationFlag{
+				Name:  "heartbeat-interval",
+				Usage: "how often clients are pinged, 0 disables pings",
+				Value: 30 * time.Second,
+			},
+			&cli.DurationFlag{
+				Name:  "idle-timeout",
+				Usage: "disconnect clients that send nothing, not even a pong, for this long, 0 disables it",
+				Value: 90 * time.Second,
+			},
+			&cli.DurationFlag{
+				Name:  "session-ttl",
+				Usage: "how long a disconnected user can resume their session",
+				Value: defaultSessionTTL,
+			},
+			&cli.StringFlag{
+				Name:  "accounts-file",
+				Usage: "file with the accounts managed by the useradd subcommand, enables login",
+			},
+			&cli.BoolFlag{
+				Name:  "auth-required",
+				Usage: "only allow users that logged in to an account",
+			},
+			&cli.StringSliceFlag{
+				Name:  "moderator",
+				Usage: "username that may edit and delete any message once logged in or authenticated by certificate, can be repeated",
+			},
 			&cli.StringFlag{
-				Name:     "address",
-				Usage:    "address to bind the server to",
-				Aliases:  []string{"a"},
-				Required: true,
+				Name:  "websocket-address",
+				Usage: "also accept WebSocket clients on " + websocketPath + " at this HTTP address, using TLS when configured",
+			},
+			&cli.StringSliceFlag{
+				Name:  "websocket-origin",
+				Usage: "origin of browser pages allowed to open a WebSocket, \"*\" allows any, can be repeated. Defaults to the same origin",
+			},
+			&cli.StringFlag{
+				Name:  "irc-address",
+				Usage: "also accept IRC clients at this address, using TLS when configured",
+			},
+			&cli.StringFlag{
+				Name:  "api-address",
+				Usage: "also serve the HTTP API under " + apiPath + " at this address, using TLS when configured",
+			},
+			&cli.StringFlag{
+				Name:    "api-token",
+				Usage:   "bearer token required by the HTTP API",
+				EnvVars: []string{"TCP_CHAT_API_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:  "api-username",
+				Usage: "username of the service account posting the messages of the HTTP API",
+				Value: defaultAPIUsername,
+			},
+			&cli.StringFlag{
+				Name:  "metrics-address",
+				Usage: "serve Prometheus metrics on " + metricsPath + " at this address, over plain HTTP",
+			},
+			&cli.StringFlag{
+				Name:  "webhooks-file",
+				Usage: "JSON file with the webhooks to post matching messages to",
+			},
+			&cli.StringSliceFlag{
+				Name:  "plugin",
+				Usage: "run a bot plugin, named after it, can be repeated. Built in: " + strings.Join(Plugins(), ", "),
 			},
 		},
 		Action: serverCommand,
+		Subcommands: []*cli.Command{
+			userAddCommand(),
+			userDelCommand(),
+		},
 	}
 }
 
 func serverCommand(ctx *cli.Context) error {
-	address := ctx.String("address")
-	return server(address)
+	if ctx.String("address") == "" {
+		return errors.New(`Required flag "address" not set`)
+	}
+	usernames, err := newUsernameRules(
+		ctx.Int("username-min-length"),
+		ctx.Int("username-max-length"),
+		ctx.String("username-pattern"),
+		ctx.StringSlice("reserved-username"),
+	)
+	if err != nil {
+		return err
+	}
+	policy, err := ParseOverflowPolicy(ctx.String("slow-consumer-policy"))
+	if err != nil {
+		return err
+	}
+	var accounts *Accounts
+	if path := ctx.String("accounts-file"); path != "" {
+		if accounts, err = LoadAccounts(path); err != nil {
+			return err
+		}
+	}
+	if ctx.Bool("auth-required") && accounts == nil {
+		return errors.New("--auth-required needs --accounts-file")
+	}
+	moderators := map[string]bool{}
+	for _, username := range ctx.StringSlice("moderator") {
+		moderators[strings.ToLower(username)] = true
+	}
+	for _, name := range ctx.StringSlice("plugin") {
+		if _, err := NewPlugin(name); err != nil {
+			return err
+		}
+	}
+	var webhooks *Webhooks
+	if path := ctx.String("webhooks-file"); path != "" {
+		if webhooks, err = LoadWebhooks(path); err != nil {
+			return err
+		}
+	}
+	if ctx.String("api-address") != "" {
+		if ctx.String("api-token") == "" {
+			return errors.New("--api-address needs --api-token")
+		}
+		if slices.Contains(ctx.StringSlice("plugin"), ctx.String("api-username")) {
+			return errors.New("--api-username is already used by a plugin")
+		}
+	}
+	if idle, interval := ctx.Duration("idle-timeout"), ctx.Duration("heartbeat-interval"); idle > 0 && idle <= interval {
+		return errors.New("--idle-timeout must be longer than --heartbeat-interval")
+	}
+	return server(config{
+		address:       ctx.String("address"),
+		historySize:   ctx.Int("history-size"),
+		historyFile:   ctx.String("history-file"),
+		historyReplay: min(ctx.Int("history-replay"), maxHistoryPage),
+		tlsCert:       ctx.String("tls-cert"),
+		tlsKey:        ctx.String("tls-key"),
+		tlsCA:         ctx.String("tls-ca"),
+		usernames:     usernames,
+		queue: QueueConfig{
+			Size:         ctx.Int("write-queue-size"),
+			WriteTimeout: ctx.Duration("write-timeout"),
+			Policy:       policy,
+		},
+		heartbeat:    ctx.Duration("heartbeat-interval"),
+		idleTimeout:  ctx.Duration("idle-timeout"),
+		sessionTTL:   ctx.Duration("session-ttl"),
+		accounts:     accounts,
+		authRequired: ctx.Bool("auth-required"),
+		moderators:   moderators,
+		plugins:      ctx.StringSlice("plugin"),
+
+		websocketAddress: ctx.String("websocket-address"),
+		websocketOrigins: ctx.StringSlice("websocket-origin"),
+		ircAddress:       ctx.String("irc-address"),
+		apiAddress:       ctx.String("api-address"),
+		apiToken:         ctx.String("api-token"),
+		apiUsername:      ctx.String("api-username"),
+		webhooks:         webhooks,
+		metricsAddress:   ctx.String("metrics-address"),
+	})
 }
 
-func server(address string) error {
-	listen, err := net.Listen(protocol, address)
+func newHistoryStore(cfg config) (HistoryStore, error) {
+	if cfg.historyFile != "" {
+		return OpenFileHistory(cfg.historyFile, cfg.historySize)
+	}
+	return NewMemoryHistory(cfg.historySize), nil
+}
+
+func server(cfg config) error {
+	history, err := newHistoryStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	hub, err := newHub(history, cfg)
+	if err != nil {
+		return err
+	}
+
+	listen, err := newListener(cfg.address, cfg)
 	if err != nil {
 		return err
 	}
 	defer listen.Close()
 
-	users := map[string]types.User{}
+	if cfg.websocketAddress != "" {
+		wsListen, err := newListener(cfg.websocketAddress, cfg)
+		if err != nil {
+			return err
+		}
+		defer wsListen.Close()
+		go func() {
+			err := http.Serve(wsListen, websocketHandler(hub, cfg))
+			log.Print("Error serving websockets: " + err.Error())
+		}()
+	}
 
-	var connMap = &sync.Map{}
+	if cfg.ircAddress != "" {
+		ircListen, err := newListener(cfg.ircAddress, cfg)
+		if err != nil {
+			return err
+		}
+		defer ircListen.Close()
+		go func() {
+			err := serveIRC(ircListen, hub, cfg)
+			log.Print("Error serving IRC: " + err.Error())
+		}()
+	}
+
+	if cfg.metricsAddress != "" {
+		metricsListen, err := net.Listen(network, cfg.metricsAddress)
+		if err != nil {
+			return err
+		}
+		defer metricsListen.Close()
+		go func() {
+			err := http.Serve(metricsListen, metricsHandler(hub))
+			log.Print("Error serving metrics: " + err.Error())
+		}()
+	}
+
+	if cfg.apiAddress != "" {
+		apiListen, err := newListener(cfg.apiAddress, cfg)
+		if err != nil {
+			return err
+		}
+		defer apiListen.Close()
+		bot := hub.AddBot(cfg.apiUsername, nil)
+		go func() {
+			err := newAPIServer(apiHandler(hub, bot, cfg.apiToken)).Serve(apiListen)
+			log.Print("Error serving the API: " + err.Error())
+		}()
+	}
+
+	return serve(listen, hub, cfg)
+}
+
+// newListener listens on address, using TLS when it is configured.
+func newListener(address string, cfg config) (net.Listener, error) {
+	if cfg.tlsCert == "" && cfg.tlsKey == "" && cfg.tlsCA == "" {
+		return net.Listen(network, address)
+	}
+	tlsConfig, err := newTLSConfig(cfg.tlsCert, cfg.tlsKey, cfg.tlsCA)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Listen(network, address, tlsConfig)
+}
+
+// newHub creates the Hub shared by all listeners and starts its bots.
+func newHub(history HistoryStore, cfg config) (*Hub, error) {
+	hub := NewHub(history, cfg.queue)
+	if cfg.sessionTTL > 0 {
+		hub.SessionTTL = cfg.sessionTTL
+	}
+	for _, name := range cfg.plugins {
+		plugin, err := NewPlugin(name)
+		if err != nil {
+			return nil, err
+		}
+		hub.AddBot(name, plugin)
+	}
+	return hub, nil
+}
+
+func serve(listen net.Listener, hub *Hub, cfg config) error {
 	for {
 		conn, err := listen.Accept()
 		if err != nil {
 			return err
 		}
 
-		u := types.NewUser(uuid.New().String(), "", conn)
-		connMap.Store(u.ID, conn)
-
-		go handleConnection(u, connMap, &users)
+		go handleConnection(hub, hub.Connect(conn), cfg)
 	}
 }
 
-func handleConnection(u types.User, connMap *sync.Map, users *map[string]types.User) {
+func handleConnection(hub *Hub, c *Client, cfg config) {
 	defer func() {
-		u.GetConn().Close()
-		delete((*users), u.ID)
-		connMap.Delete(u.ID)
+		c.Close()
+		user := types.User{ID: c.ID, Username: c.Username}
+		for _, room := range hub.Unregister(c) {
+			sendPresence(hub, types.Presence{Type: types.PresenceLeft, User: user, Room: room})
+		}
+		if dropped := c.Dropped(); dropped > 0 {
+			log.Printf("Dropped %d frames for slow client %s", dropped, c.ID)
+		}
 	}()
 
+	if cfg.heartbeat > 0 {
+		go heartbeat(c, cfg.heartbeat)
+	}
+
+	// authenticated is set once the username of c was proven by a client
+	// certificate or an account password.
+	authenticated := false
+	// typed holds when the typing notifications of c were last forwarded to
+	// each room.
+	typed := map[string]time.Time{}
+	reader := protocol.NewReader(c.GetConn())
 	for {
-		input, err := bufio.NewReader(u.GetConn()).ReadBytes('\n')
+		if cfg.idleTimeout > 0 {
+			c.GetConn().SetReadDeadline(time.Now().Add(cfg.idleTimeout))
+		}
+		input, err := reader.ReadFrame()
 		if err != nil {
 			log.Print("Error reading action: " + err.Error())
 			return
 		}
+		hub.metrics.bytesIn.Add(float64(4 + len(input)))
 
 		action := types.Action{}
 		if _, err = action.UnmarshalMsg(input); err != nil {
+			hub.metrics.unmarshalErrors.Inc()
 			log.Print("Error unmarshalling action: " + err.Error())
 			return
 		}
+		hub.metrics.actionsIn.WithLabelValues(action.Type.String()).Inc()
 
 		actionType := types.ActionType(action.Type)
-		if actionType != types.ActionTypeRegister && u.Username == "" {
-			errMsg := types.ErrorMessage{Value: "user must be registered before sending messages"}
-			var errB []byte
-			if _, err := errMsg.MarshalMsg(errB); err != nil {
-				log.Print("Failed to marshall error message: " + err.Error())
-				return
-			}
-			errB = append(errB, '\n')
-			if _, err := u.GetConn().Write(errB); err != nil {
-				log.Print("Failed to write error message: " + err.Error())
-				return
-			}
+		switch actionType {
+		case types.ActionTypePing:
+			sendAction(c, types.ActionTypePong, nil)
+			continue
+		case types.ActionTypePong:
+			continue
+		}
+		registering := actionType == types.ActionTypeRegister || actionType == types.ActionTypeLogin
+		if !registering && c.Username == "" {
+			sendError(c, action.ID, types.ErrorCodeNotRegistered, "user must be registered before sending messages")
+			continue
+		}
+		if registering && c.Username != "" {
+			sendError(c, action.ID, types.ErrorCodeAlreadyRegistered, "already registered as "+c.Username+", rename instead")
+			continue
 		}
 
 		switch actionType {
 		case types.ActionTypeRegister:
+			register := types.Register{}
+			if _, err = register.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "register", err)
+				continue
+			}
+			username := register.Username
+			name, certified := certificateUsername(c.GetConn())
+			if certified {
+				username = name
+			} else if cfg.authRequired {
+				sendError(c, action.ID, types.ErrorCodeAuthRequired, "login required")
+				continue
+			} else if cfg.accounts != nil && cfg.accounts.Exists(username) {
+				sendError(c, action.ID, types.ErrorCodeAuthRequired, "username "+username+" has an account, log in instead")
+				continue
+			}
+			// The name of a client certificate follows the same rules, so it
+			// cannot be empty or break the addressing of users.
+			if reason, ok := cfg.usernames.validate(username); !ok {
+				if certified {
+					reason = "client certificate name: " + reason
+				}
+				sendError(c, action.ID, types.ErrorCodeInvalidUsername, reason)
+				continue
+			}
+			authenticated = registerClient(hub, c, cfg, action.ID, username, register.Token) && certified
+		case types.ActionTypeLogin:
+			credentials := types.Credentials{}
+			if _, err = credentials.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "login", err)
+				continue
+			}
+			if cfg.accounts == nil || !cfg.accounts.Authenticate(credentials.Username, credentials.Password) {
+				log.Println("Failed login for user: " + credentials.Username)
+				sendError(c, action.ID, types.ErrorCodeBadCredentials, "invalid username or password")
+				continue
+			}
+			authenticated = registerClient(hub, c, cfg, action.ID, credentials.Username, credentials.Token)
+		case types.ActionTypeRename:
 			user := types.User{}
 			if _, err = user.UnmarshalMsg(action.Data); err != nil {
-				log.Print("Error unmarshalling register: " + err.Error())
-				return
-			}
-			log.Println("Registering user: " + user.Username)
-			u.Username = user.Username
-			user.ID = u.ID
-			(*users)[u.ID] = user
-			sendUsers := types.Users{}
-			for _, v := range *users {
-				sendUsers = append(sendUsers, v)
-			}
-			usersB, _ := sendUsers.MarshalMsg(nil)
-			sendActions(u.ID, connMap, types.ActionTypeGetUsers, usersB)
+				sendMalformed(hub, c, action.ID, "rename", err)
+				continue
+			}
+			if _, ok := certificateUsername(c.GetConn()); ok {
+				sendError(c, action.ID, types.ErrorCodeInvalidUsername, "username is set by the client certificate")
+				continue
+			}
+			if cfg.authRequired || (cfg.accounts != nil && cfg.accounts.Exists(user.Username)) {
+				sendError(c, action.ID, types.ErrorCodeAuthRequired, "username is set by the account")
+				continue
+			}
+			if reason, ok := cfg.usernames.validate(user.Username); !ok {
+				sendError(c, action.ID, types.ErrorCodeInvalidUsername, reason)
+				continue
+			}
+			old := c.Username
+			if err := hub.Rename(c, user.Username); err != nil {
+				sendErr(c, action.ID, err)
+				continue
+			}
+			log.Printf("Renaming user %s to %s", old, user.Username)
+			// The new username was not proven, so it grants no moderator
+			// rights.
+			authenticated = false
+			user.ID = c.ID
+			sendPresence(hub, types.Presence{Type: types.PresenceRenamed, User: user, Previous: old})
+		case types.ActionTypeJoinRoom:
+			room := types.Room{}
+			if _, err = room.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "join room", err)
+				continue
+			}
+			if !validRoomName(room.Name) {
+				sendError(c, action.ID, types.ErrorCodeInvalidRoom, "invalid room name "+room.Name)
+				continue
+			}
+			log.Printf("User %s joining room %s", c.Username, room.Name)
+			joined := hub.Join(c, room.Name)
+			sendRoom(c, hub, room.Name)
+			if joined {
+				user := types.User{ID: c.ID, Username: c.Username}
+				sendPresence(hub, types.Presence{Type: types.PresenceJoined, User: user, Room: room.Name})
+				sendHistory(c, hub.history, room.Name, 0, cfg.historyReplay)
+			}
+		case types.ActionTypeLeaveRoom:
+			room := types.Room{}
+			if _, err = room.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "leave room", err)
+				continue
+			}
+			if !hub.Leave(c, room.Name) {
+				sendError(c, action.ID, types.ErrorCodeNotRoomMember, "not a member of room "+room.Name)
+				continue
+			}
+			log.Printf("User %s leaving room %s", c.Username, room.Name)
+			room.Users = nil
+			roomB, _ := room.MarshalMsg(nil)
+			sendAction(c, types.ActionTypeLeaveRoom, roomB)
+			user := types.User{ID: c.ID, Username: c.Username}
+			sendPresence(hub, types.Presence{Type: types.PresenceLeft, User: user, Room: room.Name})
+		case types.ActionTypeGetUsers:
+			room := types.Room{}
+			if _, err = room.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "get users", err)
+				continue
+			}
+			if !hub.IsMember(room.Name, c.ID) {
+				sendError(c, action.ID, types.ErrorCodeNotRoomMember, "not a member of room "+room.Name)
+				continue
+			}
+			sendRoom(c, hub, room.Name)
+		case types.ActionTypeListRooms:
+			rooms := hub.Rooms()
+			roomsB, _ := rooms.MarshalMsg(nil)
+			sendAction(c, types.ActionTypeListRooms, roomsB)
 		case types.ActionTypeMessage:
 			message := types.Message{}
 			if _, err = message.UnmarshalMsg(action.Data); err != nil {
-				log.Print("Error unmarshalling message: " + err.Error())
-				return
+				sendMalformed(hub, c, action.ID, "message", err)
+				continue
+			}
+			if message.Room == "" {
+				message.Room = types.DefaultRoom
+			}
+			if !hub.IsMember(message.Room, c.ID) {
+				sendError(c, action.ID, types.ErrorCodeNotRoomMember, "not a member of room "+message.Room)
+				continue
+			}
+			if message.ReplyTo != 0 {
+				parent, err := hub.history.Get(message.ReplyTo)
+				if err != nil || parent.Room != message.Room || parent.Deleted {
+					sendError(c, action.ID, types.ErrorCodeMessageNotFound, fmt.Sprintf("message %d to reply to not found", message.ReplyTo))
+					continue
+				}
+			}
+			nonce := message.Nonce
+			message = types.Message{
+				UserID:    c.ID,
+				Username:  c.Username,
+				Room:      message.Room,
+				Value:     message.Value,
+				Timestamp: time.Now().UnixMilli(),
+				ReplyTo:   message.ReplyTo,
 			}
-			message.UserID = u.ID
-			messageB, _ := message.MarshalMsg(nil)
 			log.Printf("Recieved message: %+v", message)
-			sendActions(u.ID, connMap, types.ActionTypeMessage, messageB)
+			message = postMessage(hub, message, c.ID)
+			cfg.webhooks.Notify(message)
+			sendAck(c, types.Ack{Nonce: nonce, ID: message.ID, Timestamp: message.Timestamp})
+		case types.ActionTypeEdit, types.ActionTypeDelete:
+			request := types.Message{}
+			if _, err = request.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "message change", err)
+				continue
+			}
+			if actionType == types.ActionTypeEdit && request.Value == "" {
+				sendError(c, action.ID, types.ErrorCodeMalformedAction, "edited message is empty")
+				continue
+			}
+			moderator := authenticated && cfg.moderators[strings.ToLower(c.Username)]
+			message, err := changeMessage(hub, c, request, actionType == types.ActionTypeDelete, moderator)
+			if err != nil {
+				sendErr(c, action.ID, err)
+				continue
+			}
+			log.Printf("User %s changed message %d in room %s", c.Username, message.ID, message.Room)
+			messageB, _ := message.MarshalMsg(nil)
+			hub.Broadcast(message.Room, "", actionType, messageB)
+		case types.ActionTypeReact:
+			reaction := types.Reaction{}
+			if _, err = reaction.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "reaction", err)
+				continue
+			}
+			if !validReaction(reaction.Emoji) {
+				sendError(c, action.ID, types.ErrorCodeMalformedAction, "invalid reaction "+reaction.Emoji)
+				continue
+			}
+			message, err := updateMessage(hub, c, reaction.MessageID, func(message *types.Message) error {
+				return toggleReaction(message, c.ID, reaction.Emoji)
+			})
+			if err != nil {
+				sendErr(c, action.ID, err)
+				continue
+			}
+			messageB, _ := message.MarshalMsg(nil)
+			hub.Broadcast(message.Room, "", types.ActionTypeReact, messageB)
+		case types.ActionTypeTyping:
+			typing := types.Typing{}
+			if _, err = typing.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "typing", err)
+				continue
+			}
+			if !hub.IsMember(typing.Room, c.ID) {
+				sendError(c, action.ID, types.ErrorCodeNotRoomMember, "not a member of room "+typing.Room)
+				continue
+			}
+			if now := time.Now(); now.Sub(typed[typing.Room]) >= typingInterval {
+				typed[typing.Room] = now
+				typing.UserID = c.ID
+				typing.Username = c.Username
+				typingB, _ := typing.MarshalMsg(nil)
+				hub.Broadcast(typing.Room, c.ID, types.ActionTypeTyping, typingB)
+			}
+		case types.ActionTypeHistory:
+			request := types.HistoryRequest{}
+			if _, err = request.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "history request", err)
+				continue
+			}
+			if !hub.IsMember(request.Room, c.ID) {
+				sendError(c, action.ID, types.ErrorCodeNotRoomMember, "not a member of room "+request.Room)
+				continue
+			}
+			sendHistory(c, hub.history, request.Room, request.Before, min(max(request.Limit, 1), maxHistoryPage))
+		case types.ActionTypeDirect:
+			message := types.DirectMessage{}
+			if _, err = message.UnmarshalMsg(action.Data); err != nil {
+				sendMalformed(hub, c, action.ID, "direct message", err)
+				continue
+			}
+			recipient, ok := hub.FindUser(message.To)
+			if !ok {
+				sendError(c, action.ID, types.ErrorCodeRecipientOffline, "user "+message.To+" is offline")
+				continue
+			}
+			nonce := message.Nonce
+			message.UserID = c.ID
+			message.Username = c.Username
+			message.To = recipient.ID
+			message.Timestamp = time.Now().UnixMilli()
+			message.Nonce = ""
+			messageB, _ := message.MarshalMsg(nil)
+			sendAction(recipient, types.ActionTypeDirect, messageB)
+			sendAck(c, types.Ack{Nonce: nonce, Timestamp: message.Timestamp})
+		default:
+			sendError(c, action.ID, types.ErrorCodeUnknownAction, fmt.Sprintf("unknown action type %d", actionType))
 		}
 	}
 }
 
-func sendActions(id string, connMap *sync.Map, actionType types.ActionType, data []byte) {
-	action := types.Action{
-		Type: actionType,
-		Data: data,
+func sendSession(c *Client, token string, resumed bool) {
+	session := types.Session{UserID: c.ID, Token: token, Resumed: resumed}
+	sessionB, _ := session.MarshalMsg(nil)
+	sendAction(c, types.ActionTypeSession, sessionB)
+}
+
+// resume sends a client that resumed its session the rooms it is back in and,
+// when it was suspended, the messages it missed while it was disconnected.
+func resume(hub *Hub, c *Client, token string, rooms map[string]uint64, suspended bool) {
+	sendSession(c, token, true)
+	user := types.User{ID: c.ID, Username: c.Username}
+	for room, lastSeq := range rooms {
+		sendRoom(c, hub, room)
+		if !suspended {
+			continue
+		}
+		sendPresence(hub, types.Presence{Type: types.PresenceJoined, User: user, Room: room})
+		missed, err := missedMessages(hub.history, room, lastSeq)
+		if err != nil {
+			log.Print("Error reading history: " + err.Error())
+		}
+		for _, message := range missed {
+			messageB, _ := message.MarshalMsg(nil)
+			sendAction(c, types.ActionTypeMessage, messageB)
+		}
 	}
-	actionB, _ := action.MarshalMsg(nil)
-	actionB = append(actionB, '\n')
-	connMap.Range(func(key, value interface{}) bool {
-		if key == id && actionType != types.ActionTypeGetUsers {
-			return true
+}
+
+// missedMessages returns the messages of room stored after lastSeq, oldest
+// first, reading the history a page at a time.
+func missedMessages(history HistoryStore, room string, lastSeq uint64) ([]types.Message, error) {
+	pages := [][]HistoryEntry{}
+	before := uint64(0)
+	for {
+		entries, err := history.Before(room, before, maxHistoryPage)
+		if err != nil {
+			return nil, err
+		}
+		start := len(entries)
+		for start > 0 && entries[start-1].Seq > lastSeq {
+			start--
+		}
+		pages = append(pages, entries[start:])
+		if start > 0 || len(entries) < maxHistoryPage {
+			break
 		}
-		if conn, ok := value.(net.Conn); ok {
-			if _, err := conn.Write(actionB); err != nil {
-				log.Print("Error writing to connection " + err.Error())
+		before = entries[0].Seq
+	}
+	missed := []types.Message{}
+	for i := len(pages) - 1; i >= 0; i-- {
+		for _, entry := range pages[i] {
+			missed = append(missed, entry.Message)
+		}
+	}
+	return missed, nil
+}
+
+// heartbeat pings c every interval until it is closed. The pongs keep the read
+// deadline of an idle but healthy client from expiring.
+func heartbeat(c *Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sendAction(c, types.ActionTypePing, nil)
+		case <-c.Done():
+			return
+		}
+	}
+}
+
+// registerClient registers c as username, resuming the session token when it
+// is still valid. It reports whether c is registered.
+func registerClient(hub *Hub, c *Client, cfg config, actionID, username, token string) bool {
+	if token != "" {
+		rooms, suspended, previous, err := hub.Resume(c, token, username)
+		if err == nil {
+			log.Println("Resuming user: " + username)
+			resume(hub, c, token, rooms, suspended)
+			if !suspended && previous != username {
+				user := types.User{ID: c.ID, Username: username}
+				sendPresence(hub, types.Presence{Type: types.PresenceRenamed, User: user, Previous: previous})
 			}
+			return true
+		}
+		if !errors.Is(err, errSessionExpired) {
+			sendErr(c, actionID, err)
+			return false
+		}
+	}
+	if err := hub.Register(c, username); err != nil {
+		sendErr(c, actionID, err)
+		return false
+	}
+	log.Println("Registering user: " + username)
+	sendSession(c, hub.NewSession(c), false)
+	user := types.User{ID: c.ID, Username: username}
+	sendRoom(c, hub, types.DefaultRoom)
+	sendPresence(hub, types.Presence{Type: types.PresenceJoined, User: user, Room: types.DefaultRoom})
+	sendHistory(c, hub.history, types.DefaultRoom, 0, cfg.historyReplay)
+	return true
+}
+
+// validRoomName reports whether name can be used for a room. Control
+// characters and commas are rejected so the name can be an IRC channel.
+func validRoomName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " #@,") && !strings.ContainsFunc(name, unicode.IsControl)
+}
+
+// sendRoom sends the user list of room to c.
+func sendRoom(c *Client, hub *Hub, name string) {
+	room := hub.Room(name)
+	roomB, _ := room.MarshalMsg(nil)
+	sendAction(c, types.ActionTypeGetUsers, roomB)
+}
+
+// sendPresence announces presence to the other members of its room, or to
+// everyone sharing a room with the user when it is a rename.
+func sendPresence(hub *Hub, presence types.Presence) {
+	if presence.User.Username == "" {
+		return
+	}
+	presenceB, _ := presence.MarshalMsg(nil)
+	hub.notifyBots(presence)
+	if presence.Room == "" {
+		hub.BroadcastShared(presence.User.ID, types.ActionTypePresence, presenceB)
+		return
+	}
+	hub.Broadcast(presence.Room, presence.User.ID, types.ActionTypePresence, presenceB)
+}
+
+func sendHistory(c *Client, history HistoryStore, room string, before uint64, limit int) {
+	page, err := historyPage(history, room, before, limit)
+	if err != nil {
+		log.Print("Error reading history: " + err.Error())
+		return
+	}
+	pageB, _ := page.MarshalMsg(nil)
+	sendAction(c, types.ActionTypeHistory, pageB)
+}
+
+// historyPage returns up to limit messages of room older than the cursor
+// before.
+func historyPage(history HistoryStore, room string, before uint64, limit int) (types.History, error) {
+	entries, err := history.Before(room, before, limit)
+	if err != nil {
+		return types.History{}, err
+	}
+	page := types.History{Room: room, Messages: []types.Message{}}
+	for _, entry := range entries {
+		page.Messages = append(page.Messages, entry.Message)
+	}
+	if len(entries) > 0 {
+		page.Before = entries[0].Seq
+		older, _ := history.Before(room, page.Before, 1)
+		page.More = len(older) > 0
+	}
+	return page, nil
+}
+
+// changeMessage edits the message identified by request.ID, or deletes it when
+// remove is set. Only its author or a moderator may change a message, and only
+// while they are in its room.
+func changeMessage(hub *Hub, c *Client, request types.Message, remove, moderator bool) (types.Message, error) {
+	return updateMessage(hub, c, request.ID, func(message *types.Message) error {
+		if message.UserID != c.ID && !moderator {
+			return types.ErrorMessage{Code: types.ErrorCodeForbidden, Value: "only the author or a moderator can change a message"}
+		}
+		if remove {
+			message.Value = ""
+			message.Reactions = nil
+			message.Deleted = true
+		} else {
+			message.Value = request.Value
+			message.Edited = true
 		}
-		return true
+		return nil
 	})
 }
+
+// updateMessage applies update to the message id of a room c is in, which
+// must not be deleted.
+func updateMessage(hub *Hub, c *Client, id uint64, update func(*types.Message) error) (types.Message, error) {
+	// The rooms are looked up before updating the history, as the Hub may
+	// query the history while holding its lock.
+	rooms := hub.RoomsOf(c.ID)
+	return hub.history.Update(id, func(message *types.Message) error {
+		if message.Deleted || !slices.Contains(rooms, message.Room) {
+			return ErrMessageNotFound
+		}
+		return update(message)
+	})
+}
+
+// postMessage stores message, assigning its ID, and sends it to the members of
+// its room but except and to the bots.
+func postMessage(hub *Hub, message types.Message, except string) types.Message {
+	id, err := hub.history.Append(message)
+	if err != nil {
+		log.Print("Error storing message: " + err.Error())
+	}
+	message.ID = id
+	messageB, _ := message.MarshalMsg(nil)
+	hub.Broadcast(message.Room, except, types.ActionTypeMessage, messageB)
+	hub.notifyBots(message)
+	return message
+}
+
+// sendAck acknowledges a message to its sender. Messages sent without a nonce
+// are not acknowledged.
+func sendAck(c *Client, ack types.Ack) {
+	if ack.Nonce == "" {
+		return
+	}
+	ackB, _ := ack.MarshalMsg(nil)
+	sendAction(c, types.ActionTypeAck, ackB)
+}
+
+// sendMalformed reports a payload of c that could not be unmarshalled.
+func sendMalformed(hub *Hub, c *Client, id, payload string, err error) {
+	hub.metrics.unmarshalErrors.Inc()
+	log.Print("Error unmarshalling " + payload + ": " + err.Error())
+	sendError(c, id, types.ErrorCodeMalformedAction, "malformed "+payload)
+}
+
+// sendErr sends err to c, keeping its code when it is a types.ErrorMessage.
+func sendErr(c *Client, id string, err error) {
+	var errMsg types.ErrorMessage
+	if !errors.As(err, &errMsg) {
+		errMsg = types.ErrorMessage{Code: types.ErrorCodeUnknown, Value: err.Error()}
+	}
+	sendError(c, id, errMsg.Code, errMsg.Value)
+}
+
+func sendError(c *Client, id string, code types.ErrorCode, value string) {
+	errMsg := types.ErrorMessage{Code: code, Value: value, ID: id}
+	errB, _ := errMsg.MarshalMsg(nil)
+	sendAction(c, types.ActionTypeError, errB)
+}
+
+func sendAction(c *Client, actionType types.ActionType, data []byte) {
+	if err := c.Send(actionType, data); err != nil {
+		log.Print("Error writing to connection " + err.Error())
+	}
+}
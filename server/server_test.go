@@ -0,0 +1,639 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// startServer serves on a local TCP listener and returns its address.
+func startServer(t *testing.T) string {
+	t.Helper()
+	return startServerWith(t, testConfig(t))
+}
+
+func startServerWith(t *testing.T, cfg config) string {
+	t.Helper()
+	hub, err := newHub(NewMemoryHistory(10), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listenTCP(t, hub, cfg)
+}
+
+// listenTCP serves hub on a local TCP listener and returns its address.
+func listenTCP(t *testing.T, hub *Hub, cfg config) string {
+	t.Helper()
+	listen, err := net.Listen(network, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listen.Close() })
+	go serve(listen, hub, cfg)
+	return listen.Addr().String()
+}
+
+func dial(t *testing.T, address string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil reads actions from reader until one of actionType arrives.
+func readUntil(t *testing.T, conn net.Conn, reader *protocol.Reader, actionType types.ActionType) types.Action {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		action, err := reader.ReadAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action.Type == actionType {
+			return action
+		}
+	}
+}
+
+func TestUnregisteredMessageRejected(t *testing.T) {
+	conn := dial(t, startServer(t))
+
+	message := types.Message{Value: "hello"}
+	messageB, _ := message.MarshalMsg(nil)
+	action := types.Action{Type: types.ActionTypeMessage, Data: messageB, ID: "42"}
+	actionB, _ := action.MarshalMsg(nil)
+	if err := protocol.WriteFrame(conn, actionB); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := readUntil(t, conn, protocol.NewReader(conn), types.ActionTypeError)
+	errMsg := types.ErrorMessage{}
+	if _, err := errMsg.UnmarshalMsg(reply.Data); err != nil {
+		t.Fatal(err)
+	}
+	if errMsg.Code != types.ErrorCodeNotRegistered {
+		t.Errorf("expected code %d, got %d", types.ErrorCodeNotRegistered, errMsg.Code)
+	}
+	if errMsg.ID != "42" {
+		t.Errorf("expected the error to reference action 42, got %q", errMsg.ID)
+	}
+}
+
+func TestRegisterTwiceRejected(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	readPresence(t, alice, aliceReader)
+
+	registerB, _ := (&types.Register{Username: "mallory"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeRegister, registerB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeAlreadyRegistered {
+		t.Errorf("expected code %d, got %+v", types.ErrorCodeAlreadyRegistered, errMsg)
+	}
+
+	// bob keeps his name and alice sees no new presence before the message.
+	messageB, _ := (&types.Message{Value: "still bob"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeMessage, messageB)
+	alice.SetReadDeadline(time.Now().Add(5 * time.Second))
+	action, err := aliceReader.ReadAction()
+	for err == nil && action.Type != types.ActionTypeMessage {
+		if action.Type == types.ActionTypePresence {
+			t.Errorf("expected no presence after the rejected register")
+		}
+		action, err = aliceReader.ReadAction()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := types.Message{}
+	message.UnmarshalMsg(action.Data)
+	if message.Username != "bob" {
+		t.Errorf("expected the message of bob, got %+v", message)
+	}
+}
+
+// register registers username on conn and waits for the user list of the
+// default room.
+func register(t *testing.T, conn net.Conn, reader *protocol.Reader, username string) types.Room {
+	t.Helper()
+	registerB, _ := (&types.Register{Username: username}).MarshalMsg(nil)
+	if err := protocol.WriteAction(conn, types.ActionTypeRegister, registerB); err != nil {
+		t.Fatal(err)
+	}
+	action := readUntil(t, conn, reader, types.ActionTypeGetUsers)
+	room := types.Room{}
+	if _, err := room.UnmarshalMsg(action.Data); err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func readPresence(t *testing.T, conn net.Conn, reader *protocol.Reader) types.Presence {
+	t.Helper()
+	action := readUntil(t, conn, reader, types.ActionTypePresence)
+	presence := types.Presence{}
+	if _, err := presence.UnmarshalMsg(action.Data); err != nil {
+		t.Fatal(err)
+	}
+	return presence
+}
+
+func TestPresence(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+
+	bob := dial(t, address)
+	register(t, bob, protocol.NewReader(bob), "bob")
+	presence := readPresence(t, alice, aliceReader)
+	if presence.Type != types.PresenceJoined || presence.User.Username != "bob" || presence.Room != types.DefaultRoom {
+		t.Errorf("expected bob to join %s, got %+v", types.DefaultRoom, presence)
+	}
+
+	user := types.User{Username: "robert"}
+	userB, _ := user.MarshalMsg(nil)
+	if err := protocol.WriteAction(bob, types.ActionTypeRename, userB); err != nil {
+		t.Fatal(err)
+	}
+	presence = readPresence(t, alice, aliceReader)
+	if presence.Type != types.PresenceRenamed || presence.Previous != "bob" || presence.User.Username != "robert" {
+		t.Errorf("expected bob to be renamed to robert, got %+v", presence)
+	}
+
+	bob.Close()
+	presence = readPresence(t, alice, aliceReader)
+	if presence.Type != types.PresenceLeft || presence.User.Username != "robert" {
+		t.Errorf("expected robert to leave, got %+v", presence)
+	}
+}
+
+func TestIdleClientsAreEvicted(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.heartbeat = 20 * time.Millisecond
+	cfg.idleTimeout = 200 * time.Millisecond
+	address := startServerWith(t, cfg)
+
+	alive := dial(t, address)
+	aliveReader := protocol.NewReader(alive)
+	register(t, alive, aliveReader, "alive")
+	idle := dial(t, address)
+	register(t, idle, protocol.NewReader(idle), "idle")
+
+	// Answer pings on one connection only, until the other one is evicted.
+	alive.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		action, err := aliveReader.ReadAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch action.Type {
+		case types.ActionTypePing:
+			if err := protocol.WriteAction(alive, types.ActionTypePong, nil); err != nil {
+				t.Fatal(err)
+			}
+		case types.ActionTypePresence:
+			presence := types.Presence{}
+			if _, err := presence.UnmarshalMsg(action.Data); err != nil {
+				t.Fatal(err)
+			}
+			if presence.Type == types.PresenceJoined {
+				continue
+			}
+			if presence.Type != types.PresenceLeft || presence.User.Username != "idle" {
+				t.Fatalf("expected the idle client to be evicted, got %+v", presence)
+			}
+			return
+		}
+	}
+}
+
+func readSession(t *testing.T, conn net.Conn, reader *protocol.Reader) types.Session {
+	t.Helper()
+	action := readUntil(t, conn, reader, types.ActionTypeSession)
+	session := types.Session{}
+	if _, err := session.UnmarshalMsg(action.Data); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestResumeSession(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	userB, _ := (&types.Register{Username: "alice"}).MarshalMsg(nil)
+	if err := protocol.WriteAction(alice, types.ActionTypeRegister, userB); err != nil {
+		t.Fatal(err)
+	}
+	session := readSession(t, alice, aliceReader)
+	if session.Token == "" || session.Resumed {
+		t.Fatalf("expected a new session, got %+v", session)
+	}
+
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	readPresence(t, alice, aliceReader)
+
+	alice.Close()
+	if presence := readPresence(t, bob, bobReader); presence.Type != types.PresenceLeft {
+		t.Fatalf("expected alice to leave, got %+v", presence)
+	}
+	message := types.Message{Value: "missed"}
+	messageB, _ := message.MarshalMsg(nil)
+	if err := protocol.WriteAction(bob, types.ActionTypeMessage, messageB); err != nil {
+		t.Fatal(err)
+	}
+	// The message is stored before the list of rooms is answered.
+	if err := protocol.WriteAction(bob, types.ActionTypeListRooms, nil); err != nil {
+		t.Fatal(err)
+	}
+	readUntil(t, bob, bobReader, types.ActionTypeListRooms)
+
+	alice = dial(t, address)
+	aliceReader = protocol.NewReader(alice)
+	registerB, _ := (&types.Register{Username: "alice", Token: session.Token}).MarshalMsg(nil)
+	if err := protocol.WriteAction(alice, types.ActionTypeRegister, registerB); err != nil {
+		t.Fatal(err)
+	}
+	resumed := readSession(t, alice, aliceReader)
+	if !resumed.Resumed || resumed.UserID != session.UserID {
+		t.Errorf("expected session %s to be resumed, got %+v", session.UserID, resumed)
+	}
+	action := readUntil(t, alice, aliceReader, types.ActionTypeMessage)
+	if _, err := message.UnmarshalMsg(action.Data); err != nil {
+		t.Fatal(err)
+	}
+	if message.Value != "missed" {
+		t.Errorf("expected the missed message, got %+v", message)
+	}
+}
+
+func TestResumeLiveSessionRenamed(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	registerB, _ := (&types.Register{Username: "alice"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeRegister, registerB)
+	session := readSession(t, alice, aliceReader)
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+
+	// The first connection of alice is still attached when it is resumed.
+	other := dial(t, address)
+	registerB, _ = (&types.Register{Username: "alicia", Token: session.Token}).MarshalMsg(nil)
+	protocol.WriteAction(other, types.ActionTypeRegister, registerB)
+	presence := readPresence(t, bob, bobReader)
+	if presence.Type != types.PresenceRenamed || presence.Previous != "alice" || presence.User.Username != "alicia" {
+		t.Errorf("expected alice to be renamed to alicia, got %+v", presence)
+	}
+}
+
+func TestMissedMessagesPaged(t *testing.T) {
+	history := NewMemoryHistory(3 * maxHistoryPage)
+	for i := 1; i <= 2*maxHistoryPage+50; i++ {
+		history.Append(types.Message{Room: types.DefaultRoom, Value: fmt.Sprint(i)})
+	}
+	missed, err := missedMessages(history, types.DefaultRoom, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missed) != 2*maxHistoryPage+30 {
+		t.Fatalf("expected %d missed messages, got %d", 2*maxHistoryPage+30, len(missed))
+	}
+	if missed[0].Value != "21" || missed[len(missed)-1].Value != fmt.Sprint(2*maxHistoryPage+50) {
+		t.Errorf("expected the missed messages oldest first, got %s to %s", missed[0].Value, missed[len(missed)-1].Value)
+	}
+}
+
+func TestResumeUnknownSession(t *testing.T) {
+	conn := dial(t, startServer(t))
+	reader := protocol.NewReader(conn)
+	registerB, _ := (&types.Register{Username: "alice", Token: "unknown"}).MarshalMsg(nil)
+	if err := protocol.WriteAction(conn, types.ActionTypeRegister, registerB); err != nil {
+		t.Fatal(err)
+	}
+	if session := readSession(t, conn, reader); session.Resumed {
+		t.Errorf("expected a new session, got %+v", session)
+	}
+}
+
+func TestMessageAck(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+
+	before := time.Now().UnixMilli()
+	for i, nonce := range []string{"a", "b"} {
+		messageB, _ := (&types.Message{Value: "hello", Nonce: nonce}).MarshalMsg(nil)
+		if err := protocol.WriteAction(alice, types.ActionTypeMessage, messageB); err != nil {
+			t.Fatal(err)
+		}
+		action := readUntil(t, alice, aliceReader, types.ActionTypeAck)
+		ack := types.Ack{}
+		if _, err := ack.UnmarshalMsg(action.Data); err != nil {
+			t.Fatal(err)
+		}
+		if ack.Nonce != nonce || ack.ID != uint64(i+1) || ack.Timestamp < before {
+			t.Errorf("expected ack of %q with ID %d, got %+v", nonce, i+1, ack)
+		}
+
+		action = readUntil(t, bob, bobReader, types.ActionTypeMessage)
+		message := types.Message{}
+		if _, err := message.UnmarshalMsg(action.Data); err != nil {
+			t.Fatal(err)
+		}
+		if message.ID != ack.ID || message.Timestamp != ack.Timestamp || message.Nonce != "" {
+			t.Errorf("expected message %d at %d without nonce, got %+v", ack.ID, ack.Timestamp, message)
+		}
+	}
+}
+
+func TestEditAndDelete(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+
+	messageB, _ := (&types.Message{Value: "helo", Nonce: "1"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	ack := types.Ack{}
+	ack.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeAck).Data)
+
+	readChange := func(conn net.Conn, reader *protocol.Reader, actionType types.ActionType) types.Message {
+		t.Helper()
+		message := types.Message{}
+		if _, err := message.UnmarshalMsg(readUntil(t, conn, reader, actionType).Data); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	deleteB, _ := (&types.Message{ID: ack.ID}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeDelete, deleteB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeForbidden {
+		t.Errorf("expected bob to be forbidden from deleting, got %+v", errMsg)
+	}
+
+	editB, _ := (&types.Message{ID: ack.ID, Value: "hello"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeEdit, editB)
+	for _, peer := range []struct {
+		conn   net.Conn
+		reader *protocol.Reader
+	}{{alice, aliceReader}, {bob, bobReader}} {
+		edited := readChange(peer.conn, peer.reader, types.ActionTypeEdit)
+		if edited.ID != ack.ID || edited.Value != "hello" || !edited.Edited {
+			t.Errorf("expected message %d to be edited, got %+v", ack.ID, edited)
+		}
+	}
+
+	protocol.WriteAction(alice, types.ActionTypeDelete, deleteB)
+	deleted := readChange(bob, bobReader, types.ActionTypeDelete)
+	if deleted.ID != ack.ID || deleted.Value != "" || !deleted.Deleted {
+		t.Errorf("expected message %d to be deleted, got %+v", ack.ID, deleted)
+	}
+}
+
+func TestReply(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+
+	messageB, _ := (&types.Message{Value: "lunch?", Nonce: "1"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	ack := types.Ack{}
+	ack.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeAck).Data)
+
+	replyB, _ := (&types.Message{Value: "sure", ReplyTo: ack.ID, Nonce: "2"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeMessage, replyB)
+	reply := types.Message{}
+	reply.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeMessage).Data)
+	if reply.Value != "sure" || reply.ReplyTo != ack.ID {
+		t.Errorf("expected a reply to message %d, got %+v", ack.ID, reply)
+	}
+
+	orphanB, _ := (&types.Message{Value: "what?", ReplyTo: 99}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeMessage, orphanB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeMessageNotFound {
+		t.Errorf("expected replying to an unknown message to fail, got %+v", errMsg)
+	}
+}
+
+func TestTypingRateLimited(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+
+	typingB, _ := (&types.Typing{Room: types.DefaultRoom}).MarshalMsg(nil)
+	for i := 0; i < 5; i++ {
+		protocol.WriteAction(alice, types.ActionTypeTyping, typingB)
+	}
+	messageB, _ := (&types.Message{Value: "done typing"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+
+	typings := 0
+	bob.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		action, err := bobReader.ReadAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action.Type == types.ActionTypeMessage {
+			break
+		}
+		if action.Type != types.ActionTypeTyping {
+			continue
+		}
+		typing := types.Typing{}
+		typing.UnmarshalMsg(action.Data)
+		if typing.Username != "alice" || typing.Room != types.DefaultRoom {
+			t.Errorf("expected alice to type in %s, got %+v", types.DefaultRoom, typing)
+		}
+		typings++
+	}
+	if typings != 1 {
+		t.Errorf("expected 1 typing notification, got %d", typings)
+	}
+}
+
+func TestDirectMessage(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	registerB, _ := (&types.Register{Username: "bob"}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeRegister, registerB)
+	bobID := readSession(t, bob, bobReader).UserID
+
+	for _, to := range []string{"bob", "Bob", bobID} {
+		directB, _ := (&types.DirectMessage{To: to, Value: "psst " + to}).MarshalMsg(nil)
+		protocol.WriteAction(alice, types.ActionTypeDirect, directB)
+		direct := types.DirectMessage{}
+		direct.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeDirect).Data)
+		if direct.Username != "alice" || direct.To != bobID || direct.Value != "psst "+to {
+			t.Errorf("expected the direct message of alice to %s, got %+v", to, direct)
+		}
+	}
+
+	directB, _ := (&types.DirectMessage{To: "carol", Value: "hi"}).MarshalMsg(nil)
+	action := types.Action{Type: types.ActionTypeDirect, Data: directB, ID: "7"}
+	actionB, _ := action.MarshalMsg(nil)
+	protocol.WriteFrame(alice, actionB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeRecipientOffline || errMsg.ID != "7" {
+		t.Errorf("expected recipient offline for action 7, got %+v", errMsg)
+	}
+}
+
+func TestRooms(t *testing.T) {
+	address := startServer(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	readPresence(t, alice, aliceReader)
+
+	roomB, _ := (&types.Room{Name: "dev"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeJoinRoom, roomB)
+	room := types.Room{}
+	room.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeGetUsers).Data)
+	if room.Name != "dev" || len(room.Users) != 1 {
+		t.Errorf("expected alice alone in dev, got %+v", room)
+	}
+
+	protocol.WriteAction(alice, types.ActionTypeListRooms, nil)
+	rooms := types.Rooms{}
+	rooms.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeListRooms).Data)
+	if len(rooms) != 2 || rooms[0].Name != "dev" || rooms[1].Name != types.DefaultRoom {
+		t.Errorf("expected dev and %s, got %+v", types.DefaultRoom, rooms)
+	}
+
+	// Messages to dev only reach its members, so bob first sees the one sent
+	// to the default room.
+	messageB, _ := (&types.Message{Room: "dev", Value: "dev only"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	messageB, _ = (&types.Message{Value: "everyone"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	message := types.Message{}
+	message.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeMessage).Data)
+	if message.Value != "everyone" {
+		t.Errorf("expected bob to only receive the message to %s, got %+v", types.DefaultRoom, message)
+	}
+
+	protocol.WriteAction(alice, types.ActionTypeLeaveRoom, roomB)
+	room = types.Room{}
+	room.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeLeaveRoom).Data)
+	if room.Name != "dev" {
+		t.Errorf("expected leaving dev to be confirmed, got %+v", room)
+	}
+	protocol.WriteAction(alice, types.ActionTypeLeaveRoom, roomB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeNotRoomMember {
+		t.Errorf("expected leaving dev twice to fail, got %+v", errMsg)
+	}
+}
+
+func readHistory(t *testing.T, conn net.Conn, reader *protocol.Reader) types.History {
+	t.Helper()
+	page := types.History{}
+	if _, err := page.UnmarshalMsg(readUntil(t, conn, reader, types.ActionTypeHistory).Data); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestValidRoomName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"dev":       true,
+		"café":      true,
+		"":          false,
+		"two words": false,
+		"#dev":      false,
+		"a,b":       false,
+		"dev\r\n":   false,
+		"dev\x00":   false,
+	} {
+		if validRoomName(name) != valid {
+			t.Errorf("expected validRoomName(%q) to be %v", name, valid)
+		}
+	}
+}
+
+func TestHistoryReplayAndPaging(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.historyReplay = 3
+	address := startServerWith(t, cfg)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+	for i := 1; i <= 5; i++ {
+		messageB, _ := (&types.Message{Value: fmt.Sprint(i)}).MarshalMsg(nil)
+		protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	}
+	// The messages are stored before the list of rooms is answered.
+	protocol.WriteAction(alice, types.ActionTypeListRooms, nil)
+	readUntil(t, alice, aliceReader, types.ActionTypeListRooms)
+
+	bob := dial(t, address)
+	bobReader := protocol.NewReader(bob)
+	register(t, bob, bobReader, "bob")
+	page := readHistory(t, bob, bobReader)
+	if len(page.Messages) != 3 || page.Messages[0].Value != "3" || page.Messages[2].Value != "5" || !page.More {
+		t.Fatalf("expected a replay of 3 to 5 with more, got %+v", page)
+	}
+
+	requestB, _ := (&types.HistoryRequest{Room: types.DefaultRoom, Before: page.Before, Limit: 10}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeHistory, requestB)
+	page = readHistory(t, bob, bobReader)
+	if len(page.Messages) != 2 || page.Messages[0].Value != "1" || page.Messages[1].Value != "2" || page.More {
+		t.Errorf("expected the page 1 to 2 without more, got %+v", page)
+	}
+
+	requestB, _ = (&types.HistoryRequest{Room: "dev", Limit: 10}).MarshalMsg(nil)
+	protocol.WriteAction(bob, types.ActionTypeHistory, requestB)
+	errMsg := types.ErrorMessage{}
+	errMsg.UnmarshalMsg(readUntil(t, bob, bobReader, types.ActionTypeError).Data)
+	if errMsg.Code != types.ErrorCodeNotRoomMember {
+		t.Errorf("expected the history of a room bob is not in to be refused, got %+v", errMsg)
+	}
+}
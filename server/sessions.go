@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/tashima42/tcp-chat/types"
+)
+
+const defaultSessionTTL = 5 * time.Minute
+
+// session remembers a registered user so a reconnecting client can resume it.
+// While the user is offline, rooms holds the sequence number of the last
+// message of each room it was in.
+type session struct {
+	userID  string
+	rooms   map[string]uint64
+	expires time.Time
+}
+
+var errSessionExpired = types.ErrorMessage{Code: types.ErrorCodeSessionExpired, Value: "session expired"}
+
+func newSessionToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewSession creates a resumable session for the registered client c and
+// returns its token. Any previous session of c is discarded.
+func (h *Hub) NewSession(c *Client) string {
+	token := newSessionToken()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, c.session)
+	now := time.Now()
+	for t, s := range h.sessions {
+		if !s.expires.IsZero() && now.After(s.expires) {
+			delete(h.sessions, t)
+		}
+	}
+	h.sessions[token] = &session{userID: c.ID}
+	c.session = token
+	return token
+}
+
+// Resume registers c as the user of the session token, taking over its user
+// ID. It returns the rooms the user is back in, mapped to the sequence number
+// of the last message the user received in each. suspended is false when the
+// previous connection was still attached, so nothing was missed; previous is
+// then the username of that connection.
+func (h *Hub) Resume(c *Client, token, username string) (rooms map[string]uint64, suspended bool, previous string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[token]
+	if !ok || (!s.expires.IsZero() && time.Now().After(s.expires)) {
+		delete(h.sessions, token)
+		return nil, false, "", errSessionExpired
+	}
+	if h.usernameTakenLocked(username, s.userID) {
+		return nil, false, "", types.ErrorMessage{Code: types.ErrorCodeUsernameTaken, Value: "username " + username + " is already taken"}
+	}
+
+	rooms, suspended = s.rooms, true
+	if old, ok := h.clients[s.userID]; ok {
+		// The previous connection has not been detected as dead yet. It is
+		// replaced in place, so its own cleanup does not touch the rooms.
+		old.session = ""
+		old.Close()
+		previous = old.Username
+		rooms, suspended = map[string]uint64{}, false
+		for room, members := range h.rooms {
+			if _, ok := members[s.userID]; ok {
+				rooms[room] = 0
+			}
+		}
+	}
+	delete(h.clients, c.ID)
+	c.ID = s.userID
+	c.Username = username
+	c.session = token
+	h.clients[c.ID] = c
+	for room := range rooms {
+		h.joinLocked(room, c.ID)
+	}
+	s.rooms = nil
+	s.expires = time.Time{}
+	return rooms, suspended, previous, nil
+}
+
+// suspendLocked keeps the session of c for SessionTTL after it disconnected.
+func (h *Hub) suspendLocked(c *Client, rooms []string) {
+	s, ok := h.sessions[c.session]
+	if !ok {
+		return
+	}
+	s.expires = time.Now().Add(h.SessionTTL)
+	s.rooms = map[string]uint64{}
+	for _, room := range rooms {
+		s.rooms[room] = 0
+		if latest, err := h.history.Before(room, 0, 1); err == nil && len(latest) > 0 {
+			s.rooms[room] = latest[0].Seq
+		}
+	}
+}
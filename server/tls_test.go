@@ -0,0 +1,227 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns the PEM encoded certificate and key for commonName.
+func (ca testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startTLSServer serves on a local TLS listener and returns its address.
+func startTLSServer(t *testing.T, ca testCA, mutual bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	certB, keyB := ca.issue(t, "127.0.0.1", x509.ExtKeyUsageServerAuth)
+	caFile := ""
+	if mutual {
+		caFile = writeFile(t, dir, "ca.pem", ca.pem)
+	}
+	tlsConfig, err := newTLSConfig(writeFile(t, dir, "cert.pem", certB), writeFile(t, dir, "key.pem", keyB), caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen, err := tls.Listen(network, "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listen.Close() })
+	go serve(listen, NewHub(NewMemoryHistory(10), DefaultQueueConfig), testConfig(t))
+	return listen.Addr().String()
+}
+
+// registerAs registers username and returns the users of the default room.
+func registerAs(t *testing.T, conn net.Conn, username string) types.Users {
+	t.Helper()
+	registerB, _ := (&types.Register{Username: username}).MarshalMsg(nil)
+	if err := protocol.WriteAction(conn, types.ActionTypeRegister, registerB); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := protocol.NewReader(conn)
+	for {
+		action, err := reader.ReadAction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action.Type != types.ActionTypeGetUsers {
+			continue
+		}
+		room := types.Room{}
+		if _, err := room.UnmarshalMsg(action.Data); err != nil {
+			t.Fatal(err)
+		}
+		return room.Users
+	}
+}
+
+func TestTLSServer(t *testing.T) {
+	ca := newTestCA(t)
+	address := startTLSServer(t, ca, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	conn, err := tls.Dial(network, address, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	users := registerAs(t, conn, "alice")
+	if len(users) != 1 || users[0].Username != "alice" {
+		t.Errorf("expected alice to be registered, got %+v", users)
+	}
+}
+
+func TestMutualTLSUsernameFromCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	address := startTLSServer(t, ca, true)
+
+	certB, keyB := ca.issue(t, "bob", x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(certB, keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	conn, err := tls.Dial(network, address, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	users := registerAs(t, conn, "mallory")
+	if len(users) != 1 || users[0].Username != "bob" {
+		t.Errorf("expected the certificate name bob, got %+v", users)
+	}
+}
+
+func TestMutualTLSRejectsInvalidCertificateName(t *testing.T) {
+	ca := newTestCA(t)
+	address := startTLSServer(t, ca, true)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	for _, name := range []string{"", "bob smith", "bob\r\n"} {
+		certB, keyB := ca.issue(t, name, x509.ExtKeyUsageClientAuth)
+		cert, err := tls.X509KeyPair(certB, keyB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := tls.Dial(network, address, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		registerB, _ := (&types.Register{Username: "mallory"}).MarshalMsg(nil)
+		protocol.WriteAction(conn, types.ActionTypeRegister, registerB)
+		errMsg := types.ErrorMessage{}
+		errMsg.UnmarshalMsg(readUntil(t, conn, protocol.NewReader(conn), types.ActionTypeError).Data)
+		if errMsg.Code != types.ErrorCodeInvalidUsername {
+			t.Errorf("expected the certificate name %q to be rejected, got %+v", name, errMsg)
+		}
+	}
+}
+
+func TestMutualTLSRejectsMissingCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	address := startTLSServer(t, ca, true)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	conn, err := tls.Dial(network, address, &tls.Config{RootCAs: pool})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the server to reject a client without certificate")
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+var accountsFileFlag = &cli.StringFlag{
+	Name:     "accounts-file",
+	Usage:    "file storing the accounts",
+	Required: true,
+}
+
+func userAddCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "useradd",
+		Usage:     "create an account, or change its password with --update",
+		ArgsUsage: "<username>",
+		Flags: []cli.Flag{
+			accountsFileFlag,
+			&cli.BoolFlag{
+				Name:  "update",
+				Usage: "change the password of an existing account",
+			},
+		},
+		Action: userAddAction,
+	}
+}
+
+func userDelCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "userdel",
+		Usage:     "delete an account",
+		ArgsUsage: "<username>",
+		Flags:     []cli.Flag{accountsFileFlag},
+		Action:    userDelAction,
+	}
+}
+
+func userAddAction(ctx *cli.Context) error {
+	username := ctx.Args().First()
+	if username == "" {
+		return errors.New("missing username")
+	}
+	if !validAccountName(username) {
+		return ErrInvalidAccountName
+	}
+	accounts, err := LoadAccounts(ctx.String("accounts-file"))
+	if err != nil {
+		return err
+	}
+	if accounts.Exists(username) && !ctx.Bool("update") {
+		return ErrAccountExists
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	if err := accounts.Add(username, password, ctx.Bool("update")); err != nil {
+		return err
+	}
+	fmt.Printf("Saved account %s\n", username)
+	return nil
+}
+
+func userDelAction(ctx *cli.Context) error {
+	username := ctx.Args().First()
+	if username == "" {
+		return errors.New("missing username")
+	}
+	accounts, err := LoadAccounts(ctx.String("accounts-file"))
+	if err != nil {
+		return err
+	}
+	if err := accounts.Delete(username); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted account %s\n", username)
+	return nil
+}
+
+// readPassword prompts for the password twice on a terminal, or reads the
+// first line of stdin when it is not a terminal.
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", errors.New("empty password")
+		}
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	repeated, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(repeated) {
+		return "", errors.New("passwords do not match")
+	}
+	if len(password) == 0 {
+		return "", errors.New("empty password")
+	}
+	return string(password), nil
+}
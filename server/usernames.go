@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultUsernamePattern = `^[A-Za-z0-9_.-]+$`
+)
+
+var defaultReservedUsernames = []string{"admin", "server", "system"}
+
+// usernameRules decides which usernames users may register or rename to.
+type usernameRules struct {
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	reserved  []string
+}
+
+func newUsernameRules(minLength, maxLength int, pattern string, reserved []string) (usernameRules, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return usernameRules{}, fmt.Errorf("invalid username pattern: %w", err)
+	}
+	lower := []string{}
+	for _, r := range reserved {
+		lower = append(lower, strings.ToLower(r))
+	}
+	return usernameRules{
+		minLength: max(minLength, 1),
+		maxLength: maxLength,
+		pattern:   re,
+		reserved:  lower,
+	}, nil
+}
+
+// validate returns a user facing reason when username breaks the rules.
+func (r usernameRules) validate(username string) (string, bool) {
+	length := utf8.RuneCountInString(username)
+	if length < r.minLength {
+		return fmt.Sprintf("username must have at least %d characters", r.minLength), false
+	}
+	if r.maxLength > 0 && length > r.maxLength {
+		return fmt.Sprintf("username must have at most %d characters", r.maxLength), false
+	}
+	if !r.pattern.MatchString(username) {
+		return "username contains invalid characters", false
+	}
+	if slices.Contains(r.reserved, strings.ToLower(username)) {
+		return "username " + username + " is reserved", false
+	}
+	return "", true
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+)
+
+func testConfig(t *testing.T) config {
+	t.Helper()
+	usernames, err := newUsernameRules(1, 20, defaultUsernamePattern, defaultReservedUsernames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config{historyReplay: 10, usernames: usernames, queue: DefaultQueueConfig}
+}
+
+func TestUsernameRules(t *testing.T) {
+	rules, err := newUsernameRules(3, 8, defaultUsernamePattern, []string{"Admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"alice", true},
+		{"bob_01", true},
+		{"", false},
+		{"al", false},
+		{"averylongname", false},
+		{"al ice", false},
+		{"admin", false},
+		{"ADMIN", false},
+	}
+	for _, tt := range tests {
+		if _, ok := rules.validate(tt.username); ok != tt.valid {
+			t.Errorf("validate(%q): expected %t, got %t", tt.username, tt.valid, ok)
+		}
+	}
+}
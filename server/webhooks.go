@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+const (
+	// webhookWorkers is the number of deliveries made concurrently.
+	webhookWorkers = 4
+	// webhookQueueSize bounds the deliveries waiting for a worker. Messages
+	// matching while the queue is full are not delivered.
+	webhookQueueSize = 256
+	webhookTimeout   = 10 * time.Second
+
+	defaultWebhookAttempts = 5
+	defaultWebhookBackoff  = time.Second
+)
+
+// Webhook posts the messages matching all of its conditions to URL. Empty
+// conditions match every message. Keyword matches mentions of a word, with or
+// without a leading @, ignoring case.
+//
+// The payload is the types.Message as JSON, with the keys of its msgpack
+// encoding. When Secret is set, the header X-Tcp-Chat-Signature holds
+// "sha256=" followed by the hex HMAC-SHA256 of the payload keyed with it.
+type Webhook struct {
+	URL     string `json:"url"`
+	Secret  string `json:"secret"`
+	Room    string `json:"room"`
+	Pattern string `json:"pattern"`
+	Keyword string `json:"keyword"`
+
+	pattern *regexp.Regexp
+	keyword *regexp.Regexp
+}
+
+func (w *Webhook) compile() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url %q must be http or https", w.URL)
+	}
+	if w.Pattern != "" {
+		if w.pattern, err = regexp.Compile(w.Pattern); err != nil {
+			return err
+		}
+	}
+	if w.Keyword != "" {
+		w.keyword = regexp.MustCompile(`(?i)(^|\W)@?` + regexp.QuoteMeta(w.Keyword) + `($|\W)`)
+	}
+	return nil
+}
+
+// Matches reports whether message meets every condition of w.
+func (w *Webhook) Matches(message types.Message) bool {
+	if w.Room != "" && w.Room != message.Room {
+		return false
+	}
+	if w.pattern != nil && !w.pattern.MatchString(message.Value) {
+		return false
+	}
+	if w.keyword != nil && !w.keyword.MatchString(message.Value) {
+		return false
+	}
+	return true
+}
+
+type webhookDelivery struct {
+	hook    *Webhook
+	id      string
+	payload []byte
+}
+
+// Webhooks delivers messages to the webhooks they match. Deliveries failing
+// with a network error, a 429 or a 5xx response are retried with exponential
+// backoff.
+type Webhooks struct {
+	hooks  []*Webhook
+	client *http.Client
+	queue  chan webhookDelivery
+	// Attempts is the number of times a delivery is tried.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled before each
+	// following one.
+	Backoff time.Duration
+}
+
+// NewWebhooks validates hooks and starts delivering to them.
+func NewWebhooks(hooks []Webhook) (*Webhooks, error) {
+	w := &Webhooks{
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan webhookDelivery, webhookQueueSize),
+		Attempts: defaultWebhookAttempts,
+		Backoff:  defaultWebhookBackoff,
+	}
+	for i := range hooks {
+		hook := hooks[i]
+		if err := hook.compile(); err != nil {
+			return nil, err
+		}
+		w.hooks = append(w.hooks, &hook)
+	}
+	for i := 0; i < webhookWorkers; i++ {
+		go w.run()
+	}
+	return w, nil
+}
+
+// LoadWebhooks reads a JSON array of webhooks from the file at path.
+func LoadWebhooks(path string) (*Webhooks, error) {
+	hooksB, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hooks := []Webhook{}
+	if err := json.Unmarshal(hooksB, &hooks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewWebhooks(hooks)
+}
+
+// Notify queues message for every webhook it matches. It does nothing on a nil
+// Webhooks.
+func (w *Webhooks) Notify(message types.Message) {
+	if w == nil {
+		return
+	}
+	var payload []byte
+	for _, hook := range w.hooks {
+		if !hook.Matches(message) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = marshalJSON(&message); err != nil {
+				log.Print("Error marshalling webhook payload: " + err.Error())
+				return
+			}
+		}
+		select {
+		case w.queue <- webhookDelivery{hook: hook, id: uuid.New().String(), payload: payload}:
+		default:
+			log.Print("Error queueing webhook to " + hook.URL + ": queue is full")
+		}
+	}
+}
+
+func (w *Webhooks) run() {
+	for delivery := range w.queue {
+		w.deliver(delivery)
+	}
+}
+
+// deliver posts a delivery until it succeeds, fails permanently or runs out of
+// attempts.
+func (w *Webhooks) deliver(delivery webhookDelivery) {
+	backoff := w.Backoff
+	for attempt := 1; ; attempt++ {
+		err := w.post(delivery)
+		if err == nil {
+			return
+		}
+		var permanent errWebhookRejected
+		if errors.As(err, &permanent) || attempt >= w.Attempts {
+			log.Printf("Error delivering webhook %s to %s after %d attempts: %s", delivery.id, delivery.hook.URL, attempt, err.Error())
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// errWebhookRejected is returned for responses that are not worth retrying.
+type errWebhookRejected struct {
+	status int
+}
+
+func (e errWebhookRejected) Error() string {
+	return fmt.Sprintf("rejected with status %d", e.status)
+}
+
+func (w *Webhooks) post(delivery webhookDelivery) error {
+	req, err := http.NewRequest(http.MethodPost, delivery.hook.URL, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tcp-Chat-Delivery", delivery.id)
+	if delivery.hook.Secret != "" {
+		req.Header.Set("X-Tcp-Chat-Signature", signWebhook(delivery.hook.Secret, delivery.payload))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("failed with status %d", resp.StatusCode)
+	}
+	return errWebhookRejected{status: resp.StatusCode}
+}
+
+// signWebhook returns the signature header of payload.
+func signWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
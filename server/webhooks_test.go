@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+func TestWebhookMatches(t *testing.T) {
+	hooks, err := NewWebhooks([]Webhook{
+		{URL: "http://example.com", Room: "deploys"},
+		{URL: "http://example.com", Pattern: `^!deploy \w+$`},
+		{URL: "http://example.com", Keyword: "oncall"},
+		{URL: "http://example.com", Room: "ops", Keyword: "oncall"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		message types.Message
+		matches []bool
+	}{
+		{types.Message{Room: "deploys", Value: "hello"}, []bool{true, false, false, false}},
+		{types.Message{Room: "general", Value: "!deploy api"}, []bool{false, true, false, false}},
+		{types.Message{Room: "general", Value: "ping @OnCall please"}, []bool{false, false, true, false}},
+		{types.Message{Room: "ops", Value: "oncall, help"}, []bool{false, false, true, true}},
+		{types.Message{Room: "ops", Value: "oncallers"}, []bool{false, false, false, false}},
+	}
+	for _, test := range tests {
+		for i, hook := range hooks.hooks {
+			if matches := hook.Matches(test.message); matches != test.matches[i] {
+				t.Errorf("expected webhook %d matching %+v to be %t", i, test.message, test.matches[i])
+			}
+		}
+	}
+}
+
+func TestNewWebhooksInvalid(t *testing.T) {
+	for _, hook := range []Webhook{
+		{URL: "ftp://example.com"},
+		{URL: "http://example.com", Pattern: "("},
+	} {
+		if _, err := NewWebhooks([]Webhook{hook}); err == nil {
+			t.Errorf("expected %+v to be rejected", hook)
+		}
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails, so the delivery is retried.
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	t.Cleanup(receiver.Close)
+
+	hooks, err := NewWebhooks([]Webhook{{URL: receiver.URL, Secret: "secret", Keyword: "deploy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks.Backoff = time.Millisecond
+	cfg := testConfig(t)
+	cfg.webhooks = hooks
+	conn := dial(t, startServerWith(t, cfg))
+	register(t, conn, protocol.NewReader(conn), "alice")
+
+	messageB, _ := (&types.Message{Value: "no match"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeMessage, messageB)
+	messageB, _ = (&types.Message{Value: "please deploy"}).MarshalMsg(nil)
+	protocol.WriteAction(conn, types.ActionTypeMessage, messageB)
+
+	var r *http.Request
+	select {
+	case r = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	body := <-bodies
+	if signature := r.Header.Get("X-Tcp-Chat-Signature"); signature != signWebhook("secret", body) {
+		t.Errorf("unexpected signature %q", signature)
+	}
+	message := types.Message{}
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Username != "alice" || message.Value != "please deploy" || message.ID == 0 {
+		t.Errorf("unexpected payload %s", body)
+	}
+	if calls := calls.Load(); calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestWebhookRejectedNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(receiver.Close)
+
+	hooks, err := NewWebhooks([]Webhook{{URL: receiver.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks.Backoff = time.Millisecond
+	hooks.deliver(webhookDelivery{hook: hooks.hooks[0], id: "1", payload: []byte("{}")})
+	if calls := calls.Load(); calls != 1 {
+		t.Errorf("expected a single attempt, got %d", calls)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// websocketPath is the endpoint WebSocket clients connect to. Adding
+// ?encoding=json makes the server send JSON text messages instead of msgpack
+// binary messages.
+const websocketPath = "/ws"
+
+// jsonAction is the JSON form of types.Action. Data holds the payload as a
+// JSON object, with the same keys as its msgpack encoding.
+type jsonAction struct {
+	Type types.ActionType `json:"type"`
+	Data json.RawMessage  `json:"data,omitempty"`
+	ID   string           `json:"id,omitempty"`
+}
+
+// newPayload returns the payload sent by clients with actionType, or nil for
+// actions without a payload.
+func newPayload(actionType types.ActionType) (msgp.Marshaler, bool) {
+	switch actionType {
+	case types.ActionTypeRegister:
+		return &types.Register{}, true
+	case types.ActionTypeLogin:
+		return &types.Credentials{}, true
+	case types.ActionTypeMessage, types.ActionTypeEdit, types.ActionTypeDelete:
+		return &types.Message{}, true
+	case types.ActionTypeGetUsers, types.ActionTypeJoinRoom, types.ActionTypeLeaveRoom:
+		return &types.Room{}, true
+	case types.ActionTypeDirect:
+		return &types.DirectMessage{}, true
+	case types.ActionTypeHistory:
+		return &types.HistoryRequest{}, true
+	case types.ActionTypeRename:
+		return &types.User{}, true
+	case types.ActionTypeReact:
+		return &types.Reaction{}, true
+	case types.ActionTypeTyping:
+		return &types.Typing{}, true
+	case types.ActionTypeListRooms, types.ActionTypePing, types.ActionTypePong:
+		return nil, true
+	}
+	return nil, false
+}
+
+// wsConn adapts a WebSocket connection to the framed stream handled by
+// handleConnection, so WebSocket clients share the Hub with TCP clients. Every
+// WebSocket message carries one types.Action: msgpack in binary messages and
+// JSON in text messages.
+type wsConn struct {
+	ws *websocket.Conn
+	// json makes the actions sent to the client JSON text messages.
+	json  bool
+	state *tls.ConnectionState
+	// read holds the part of the last received frame not read yet.
+	read   []byte
+	frames frameBuffer
+	// mu guards the writes to ws, which come from the Client and from Read
+	// answering actions it could not convert.
+	mu sync.Mutex
+}
+
+func newWSConn(ws *websocket.Conn, json bool, state *tls.ConnectionState) *wsConn {
+	ws.SetReadLimit(protocol.MaxFrameSize)
+	return &wsConn{ws: ws, json: json, state: state}
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for len(c.read) == 0 {
+		messageType, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if messageType == websocket.TextMessage {
+			if data, err = jsonToAction(data); err != nil {
+				// Like a malformed action over TCP, it is answered with an
+				// error rather than closing the connection.
+				if err := c.writeError(err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
+		if c.read, err = protocol.AppendFrame(nil, data); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.read)
+	c.read = c.read[n:]
+	return n, nil
+}
+
+// Write sends every frame of p as a WebSocket message.
+func (c *wsConn) Write(p []byte) (int, error) {
+	return c.frames.write(p, func(frame []byte) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.json {
+			return c.writeJSON(frame)
+		}
+		return c.ws.WriteMessage(websocket.BinaryMessage, frame)
+	})
+}
+
+// writeError sends err, a types.ErrorMessage returned by jsonToAction, to the
+// client.
+func (c *wsConn) writeError(err error) error {
+	var errMsg types.ErrorMessage
+	if !errors.As(err, &errMsg) {
+		return err
+	}
+	errB, err := errMsg.MarshalMsg(nil)
+	if err != nil {
+		return err
+	}
+	frame, err := (&types.Action{Type: types.ActionTypeError, Data: errB}).MarshalMsg(nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeJSON(frame)
+}
+
+func (c *wsConn) writeJSON(frame []byte) error {
+	action := types.Action{}
+	if _, err := action.UnmarshalMsg(frame); err != nil {
+		return err
+	}
+	message := jsonAction{Type: action.Type, ID: action.ID}
+	if len(action.Data) > 0 {
+		var data bytes.Buffer
+		if _, err := msgp.UnmarshalAsJSON(&data, action.Data); err != nil {
+			return err
+		}
+		message.Data = data.Bytes()
+	}
+	messageB, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.ws.WriteMessage(websocket.TextMessage, messageB)
+}
+
+// jsonToAction converts a JSON action received from a client to msgpack. Its
+// errors are types.ErrorMessage values to send back to the client.
+func jsonToAction(data []byte) ([]byte, error) {
+	message := jsonAction{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		log.Print("Error unmarshalling action: " + err.Error())
+		return nil, types.ErrorMessage{Code: types.ErrorCodeMalformedAction, Value: "malformed action"}
+	}
+	action := types.Action{Type: message.Type, ID: message.ID}
+	payload, ok := newPayload(message.Type)
+	if !ok {
+		return nil, types.ErrorMessage{Code: types.ErrorCodeUnknownAction, Value: fmt.Sprintf("unknown action type %d", message.Type), ID: message.ID}
+	}
+	if payload != nil && len(message.Data) > 0 {
+		if err := json.Unmarshal(message.Data, payload); err != nil {
+			log.Print("Error unmarshalling payload: " + err.Error())
+			return nil, types.ErrorMessage{Code: types.ErrorCodeMalformedAction, Value: "malformed payload", ID: message.ID}
+		}
+		payloadB, err := payload.MarshalMsg(nil)
+		if err != nil {
+			return nil, err
+		}
+		action.Data = payloadB
+	}
+	return action.MarshalMsg(nil)
+}
+
+// ConnectionState returns the TLS state of the HTTP request that opened the
+// WebSocket, so client certificates work as they do over TCP.
+func (c *wsConn) ConnectionState() tls.ConnectionState {
+	if c.state == nil {
+		return tls.ConnectionState{}
+	}
+	return *c.state
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+func (c *wsConn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
+
+// websocketHandler upgrades requests to websocketPath and serves them from
+// hub. Browsers on other origins are only accepted when listed in
+// cfg.websocketOrigins, which may contain "*".
+func websocketHandler(hub *Hub, cfg config) http.Handler {
+	upgrader := websocket.Upgrader{}
+	if len(cfg.websocketOrigins) > 0 {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || slices.Contains(cfg.websocketOrigins, "*") || slices.Contains(cfg.websocketOrigins, origin)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(websocketPath, func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Print("Error upgrading to websocket: " + err.Error())
+			return
+		}
+		conn := newWSConn(ws, r.URL.Query().Get("encoding") == "json", r.TLS)
+		handleConnection(hub, hub.Connect(conn), cfg)
+	})
+	return mux
+}
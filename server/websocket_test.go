@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tashima42/tcp-chat/protocol"
+	"github.com/tashima42/tcp-chat/types"
+)
+
+// startGateway serves one hub over TCP and WebSocket, returning the TCP
+// address and the WebSocket URL.
+func startGateway(t *testing.T) (string, string) {
+	t.Helper()
+	cfg := testConfig(t)
+	hub, err := newHub(NewMemoryHistory(10), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(websocketHandler(hub, cfg))
+	t.Cleanup(server.Close)
+	return listenTCP(t, hub, cfg), "ws" + strings.TrimPrefix(server.URL, "http") + websocketPath
+}
+
+func dialWebSocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// readWebSocketUntil reads binary actions from ws until one of actionType
+// arrives.
+func readWebSocketUntil(t *testing.T, ws *websocket.Conn, actionType types.ActionType) types.Action {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("expected a binary message, got type %d", messageType)
+		}
+		action := types.Action{}
+		if _, err := action.UnmarshalMsg(data); err != nil {
+			t.Fatal(err)
+		}
+		if action.Type == actionType {
+			return action
+		}
+	}
+}
+
+func writeWebSocketAction(t *testing.T, ws *websocket.Conn, actionType types.ActionType, data []byte) {
+	t.Helper()
+	actionB, _ := (&types.Action{Type: actionType, Data: data}).MarshalMsg(nil)
+	if err := ws.WriteMessage(websocket.BinaryMessage, actionB); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketSharesHub(t *testing.T) {
+	address, url := startGateway(t)
+	alice := dial(t, address)
+	aliceReader := protocol.NewReader(alice)
+	register(t, alice, aliceReader, "alice")
+
+	bob := dialWebSocket(t, url)
+	registerB, _ := (&types.Register{Username: "bob"}).MarshalMsg(nil)
+	writeWebSocketAction(t, bob, types.ActionTypeRegister, registerB)
+	room := types.Room{}
+	room.UnmarshalMsg(readWebSocketUntil(t, bob, types.ActionTypeGetUsers).Data)
+	if len(room.Users) != 2 {
+		t.Errorf("expected alice and bob in %s, got %+v", types.DefaultRoom, room.Users)
+	}
+	if presence := readPresence(t, alice, aliceReader); presence.User.Username != "bob" {
+		t.Errorf("expected bob to join, got %+v", presence)
+	}
+
+	messageB, _ := (&types.Message{Value: "hello from the browser"}).MarshalMsg(nil)
+	writeWebSocketAction(t, bob, types.ActionTypeMessage, messageB)
+	message := types.Message{}
+	message.UnmarshalMsg(readUntil(t, alice, aliceReader, types.ActionTypeMessage).Data)
+	if message.Username != "bob" || message.Value != "hello from the browser" {
+		t.Errorf("expected the message of bob, got %+v", message)
+	}
+
+	messageB, _ = (&types.Message{Value: "hello from the terminal"}).MarshalMsg(nil)
+	protocol.WriteAction(alice, types.ActionTypeMessage, messageB)
+	message.UnmarshalMsg(readWebSocketUntil(t, bob, types.ActionTypeMessage).Data)
+	if message.Username != "alice" || message.Value != "hello from the terminal" {
+		t.Errorf("expected the message of alice, got %+v", message)
+	}
+}
+
+func TestWebSocketJSON(t *testing.T) {
+	_, url := startGateway(t)
+	ws := dialWebSocket(t, url+"?encoding=json")
+
+	readJSONUntil := func(actionType types.ActionType) jsonAction {
+		t.Helper()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if messageType != websocket.TextMessage {
+				t.Fatalf("expected a text message, got type %d", messageType)
+			}
+			action := jsonAction{}
+			if err := json.Unmarshal(data, &action); err != nil {
+				t.Fatal(err)
+			}
+			if action.Type == actionType {
+				return action
+			}
+		}
+	}
+
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type": 1, "data": {"username": "carol"}}`))
+	room := struct {
+		Name  string
+		Users []struct{ Username string }
+	}{}
+	if err := json.Unmarshal(readJSONUntil(types.ActionTypeGetUsers).Data, &room); err != nil {
+		t.Fatal(err)
+	}
+	if room.Name != types.DefaultRoom || len(room.Users) != 1 || room.Users[0].Username != "carol" {
+		t.Errorf("expected carol alone in %s, got %+v", types.DefaultRoom, room)
+	}
+
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type": 2, "id": "7", "data": {"value": "hi", "nonce": "n1"}}`))
+	ack := types.Ack{}
+	if err := json.Unmarshal(readJSONUntil(types.ActionTypeAck).Data, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.Nonce != "n1" || ack.ID == 0 {
+		t.Errorf("expected the message to be acknowledged, got %+v", ack)
+	}
+
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type": 4, "id": "8", "data": {"name": "bad room"}}`))
+	errMsg := types.ErrorMessage{}
+	reply := readJSONUntil(types.ActionTypeError)
+	if err := json.Unmarshal(reply.Data, &errMsg); err != nil {
+		t.Fatal(err)
+	}
+	if errMsg.Code != types.ErrorCodeInvalidRoom || errMsg.ID != "8" {
+		t.Errorf("expected an invalid room error for action 8, got %+v", errMsg)
+	}
+
+	// Actions that cannot be converted are answered with an error too, and
+	// the connection stays open.
+	for _, test := range []struct {
+		message string
+		code    types.ErrorCode
+		id      string
+	}{
+		{`not json`, types.ErrorCodeMalformedAction, ""},
+		{`{"type": 99, "id": "9"}`, types.ErrorCodeUnknownAction, "9"},
+		{`{"type": 2, "id": "10", "data": {"value": 1}}`, types.ErrorCodeMalformedAction, "10"},
+	} {
+		ws.WriteMessage(websocket.TextMessage, []byte(test.message))
+		errMsg := types.ErrorMessage{}
+		if err := json.Unmarshal(readJSONUntil(types.ActionTypeError).Data, &errMsg); err != nil {
+			t.Fatal(err)
+		}
+		if errMsg.Code != test.code || errMsg.ID != test.id {
+			t.Errorf("expected error code %d for action %q of %s, got %+v", test.code, test.id, test.message, errMsg)
+		}
+	}
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type": 2, "data": {"value": "still here", "nonce": "n2"}}`))
+	if err := json.Unmarshal(readJSONUntil(types.ActionTypeAck).Data, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.Nonce != "n2" {
+		t.Errorf("expected the connection to stay open, got %+v", ack)
+	}
+}
@@ -7,13 +7,177 @@ type ActionType int
 const (
 	ActionTypeRegister ActionType = 1
 	ActionTypeMessage  ActionType = 2
-	ActionTypeGetUsers ActionType = 3
+	// ActionTypeGetUsers carries the users of a Room. Clients request it
+	// with the Name of a room they are in.
+	ActionTypeGetUsers  ActionType = 3
+	ActionTypeJoinRoom  ActionType = 4
+	ActionTypeLeaveRoom ActionType = 5
+	ActionTypeListRooms ActionType = 6
+	ActionTypeDirect    ActionType = 7
+	ActionTypeError     ActionType = 8
+	ActionTypeHistory   ActionType = 9
+	ActionTypeRename    ActionType = 10
+	ActionTypePresence  ActionType = 11
+	ActionTypePing      ActionType = 12
+	ActionTypePong      ActionType = 13
+	ActionTypeSession   ActionType = 14
+	ActionTypeLogin     ActionType = 15
+	ActionTypeAck       ActionType = 16
+	ActionTypeEdit      ActionType = 17
+	ActionTypeDelete    ActionType = 18
+	ActionTypeReact     ActionType = 19
+	ActionTypeTyping    ActionType = 20
 )
 
+func (t ActionType) String() string {
+	switch t {
+	case ActionTypeRegister:
+		return "register"
+	case ActionTypeMessage:
+		return "message"
+	case ActionTypeGetUsers:
+		return "get_users"
+	case ActionTypeJoinRoom:
+		return "join_room"
+	case ActionTypeLeaveRoom:
+		return "leave_room"
+	case ActionTypeListRooms:
+		return "list_rooms"
+	case ActionTypeDirect:
+		return "direct"
+	case ActionTypeError:
+		return "error"
+	case ActionTypeHistory:
+		return "history"
+	case ActionTypeRename:
+		return "rename"
+	case ActionTypePresence:
+		return "presence"
+	case ActionTypePing:
+		return "ping"
+	case ActionTypePong:
+		return "pong"
+	case ActionTypeSession:
+		return "session"
+	case ActionTypeLogin:
+		return "login"
+	case ActionTypeAck:
+		return "ack"
+	case ActionTypeEdit:
+		return "edit"
+	case ActionTypeDelete:
+		return "delete"
+	case ActionTypeReact:
+		return "react"
+	case ActionTypeTyping:
+		return "typing"
+	default:
+		return "unknown"
+	}
+}
+
+type PresenceType int
+
+const (
+	PresenceJoined  PresenceType = 1
+	PresenceLeft    PresenceType = 2
+	PresenceRenamed PresenceType = 3
+)
+
+type ErrorCode int
+
+const (
+	ErrorCodeUnknown          ErrorCode = 0
+	ErrorCodeInvalidUsername  ErrorCode = 1
+	ErrorCodeUsernameTaken    ErrorCode = 2
+	ErrorCodeRecipientOffline ErrorCode = 3
+	ErrorCodeNotRegistered    ErrorCode = 4
+	ErrorCodeMalformedAction  ErrorCode = 5
+	ErrorCodeUnknownAction    ErrorCode = 6
+	ErrorCodeInvalidRoom      ErrorCode = 7
+	ErrorCodeNotRoomMember    ErrorCode = 8
+	ErrorCodeSessionExpired   ErrorCode = 9
+	ErrorCodeAuthRequired     ErrorCode = 10
+	ErrorCodeBadCredentials   ErrorCode = 11
+	ErrorCodeMessageNotFound  ErrorCode = 12
+	ErrorCodeForbidden        ErrorCode = 13
+	// ErrorCodeAlreadyRegistered rejects a Register or Login sent by a
+	// registered client, which must change its name with ActionTypeRename.
+	ErrorCodeAlreadyRegistered ErrorCode = 14
+	// ErrorCodeKicked is sent to a client right before the server disconnects
+	// it for good. The client must not reconnect.
+	ErrorCodeKicked ErrorCode = 15
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrorCodeInvalidUsername:
+		return "invalid username"
+	case ErrorCodeUsernameTaken:
+		return "username taken"
+	case ErrorCodeRecipientOffline:
+		return "recipient offline"
+	case ErrorCodeNotRegistered:
+		return "not registered"
+	case ErrorCodeMalformedAction:
+		return "malformed action"
+	case ErrorCodeUnknownAction:
+		return "unknown action"
+	case ErrorCodeInvalidRoom:
+		return "invalid room"
+	case ErrorCodeNotRoomMember:
+		return "not a room member"
+	case ErrorCodeSessionExpired:
+		return "session expired"
+	case ErrorCodeAuthRequired:
+		return "authentication required"
+	case ErrorCodeBadCredentials:
+		return "bad credentials"
+	case ErrorCodeMessageNotFound:
+		return "message not found"
+	case ErrorCodeForbidden:
+		return "forbidden"
+	case ErrorCodeAlreadyRegistered:
+		return "already registered"
+	case ErrorCodeKicked:
+		return "kicked"
+	default:
+		return "unknown error"
+	}
+}
+
+// DefaultRoom is the room every user joins when registering.
+const DefaultRoom = "general"
+
 //go:generate msgp
 type Action struct {
 	Type ActionType //`msg:"type"`
 	Data []byte     //`msg:"data"`
+	// ID is chosen by the client and echoed in errors caused by this action.
+	ID string //`msg:"id"`
+}
+
+// Register is the payload of ActionTypeRegister. A Token received in a
+// previous Session resumes that session, keeping the user ID and rooms.
+type Register struct {
+	Username string //`msg:"username"`
+	Token    string //`msg:"token"`
+}
+
+// Credentials is the payload of ActionTypeLogin. Like Register, it can carry
+// the Token of a session to resume.
+type Credentials struct {
+	Username string //`msg:"username"`
+	Password string //`msg:"password"`
+	Token    string //`msg:"token"`
+}
+
+// Session is sent after a successful registration. Token can be used to
+// resume the session after reconnecting.
+type Session struct {
+	UserID  string //`msg:"userId"`
+	Token   string //`msg:"token"`
+	Resumed bool   //`msg:"resumed"`
 }
 
 type User struct {
@@ -35,11 +199,114 @@ func (u *User) GetConn() net.Conn {
 	return u.conn
 }
 
+// Message is sent to a room. ID and Timestamp, in Unix milliseconds, are
+// assigned by the server; IDs grow monotonically. Nonce is chosen by the
+// sender and only echoed back in the Ack.
+//
+// Message is also the payload of ActionTypeEdit and ActionTypeDelete, where
+// only ID and, for edits, Value are read. The server broadcasts the changed
+// message with Edited or Deleted set; deleted messages have an empty Value.
 type Message struct {
-	UserID string //`msg:"userId"`
-	Value  string //`msg:"value"`
+	ID        uint64          //`msg:"id"`
+	UserID    string          //`msg:"userId"`
+	Username  string          //`msg:"username"`
+	Room      string          //`msg:"room"`
+	Value     string          //`msg:"value"`
+	Timestamp int64           //`msg:"timestamp"`
+	Nonce     string          //`msg:"nonce"`
+	Edited    bool            //`msg:"edited"`
+	Deleted   bool            //`msg:"deleted"`
+	Reactions []ReactionCount //`msg:"reactions"`
+	// ReplyTo is the ID of the message of the same room this one replies
+	// to, or zero.
+	ReplyTo uint64 //`msg:"replyTo"`
 }
 
+// Reaction is the payload of ActionTypeReact. It toggles the reaction Emoji of
+// the sender on the message MessageID. The server broadcasts the updated
+// Message with ActionTypeReact.
+type Reaction struct {
+	MessageID uint64 //`msg:"messageId"`
+	Emoji     string //`msg:"emoji"`
+}
+
+// ReactionCount aggregates the users that reacted to a message with Emoji.
+type ReactionCount struct {
+	Emoji   string   //`msg:"emoji"`
+	Count   int      //`msg:"count"`
+	UserIDs []string //`msg:"userIds"`
+}
+
+type Room struct {
+	Name  string //`msg:"name"`
+	Users Users  //`msg:"users"`
+}
+type Rooms []Room
+
+// Presence announces a user joining or leaving Room, or changing their name
+// from Previous to User.Username. Room is empty for renames.
+type Presence struct {
+	Type     PresenceType //`msg:"type"`
+	User     User         //`msg:"user"`
+	Room     string       //`msg:"room"`
+	Previous string       //`msg:"previous"`
+}
+
+// Typing is the payload of ActionTypeTyping, sent while a user is writing a
+// message to Room. UserID and Username are set by the server, which forwards it
+// to the other members of Room without storing it.
+type Typing struct {
+	UserID   string //`msg:"userId"`
+	Username string //`msg:"username"`
+	Room     string //`msg:"room"`
+}
+
+// HistoryRequest asks for up to Limit messages of Room older than the cursor
+// Before. A zero Before requests the most recent messages.
+type HistoryRequest struct {
+	Room   string //`msg:"room"`
+	Before uint64 //`msg:"before"`
+	Limit  int    //`msg:"limit"`
+}
+
+// History is a page of messages of Room, oldest first. Before is the cursor to
+// request the previous page and More reports whether one exists.
+type History struct {
+	Room     string    //`msg:"room"`
+	Messages []Message //`msg:"messages"`
+	Before   uint64    //`msg:"before"`
+	More     bool      //`msg:"more"`
+}
+
+// DirectMessage is delivered only to the user identified by To, which may be
+// either a user ID or a username. UserID, Username and Timestamp are set by the
+// server.
+type DirectMessage struct {
+	UserID    string //`msg:"userId"`
+	Username  string //`msg:"username"`
+	To        string //`msg:"to"`
+	Value     string //`msg:"value"`
+	Timestamp int64  //`msg:"timestamp"`
+	Nonce     string //`msg:"nonce"`
+}
+
+// Ack is sent back to the sender of a Message or DirectMessage once the server
+// accepted it. Nonce is the nonce of the acknowledged message and ID is zero
+// for direct messages.
+type Ack struct {
+	Nonce     string //`msg:"nonce"`
+	ID        uint64 //`msg:"id"`
+	Timestamp int64  //`msg:"timestamp"`
+}
+
+// ErrorMessage is the payload of ActionTypeError. ID is the ID of the action
+// that failed, if it had one.
 type ErrorMessage struct {
-	Value string //`msg:"value"`
+	Code  ErrorCode //`msg:"code"`
+	Value string    //`msg:"value"`
+	ID    string    //`msg:"id"`
+}
+
+func (e ErrorMessage) Error() string {
+	return e.Code.String() + ": " + e.Value
 }
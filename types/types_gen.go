@@ -6,6 +6,159 @@ import (
 	"github.com/tinylib/msgp/msgp"
 )
 
+// DecodeMsg implements msgp.Decodable
+func (z *Ack) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Nonce":
+			z.Nonce, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Nonce")
+				return
+			}
+		case "ID":
+			z.ID, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
+		case "Timestamp":
+			z.Timestamp, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Timestamp")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z Ack) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "Nonce"
+	err = en.Append(0x83, 0xa5, 0x4e, 0x6f, 0x6e, 0x63, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Nonce)
+	if err != nil {
+		err = msgp.WrapError(err, "Nonce")
+		return
+	}
+	// write "ID"
+	err = en.Append(0xa2, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.ID)
+	if err != nil {
+		err = msgp.WrapError(err, "ID")
+		return
+	}
+	// write "Timestamp"
+	err = en.Append(0xa9, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.Timestamp)
+	if err != nil {
+		err = msgp.WrapError(err, "Timestamp")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Ack) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "Nonce"
+	o = append(o, 0x83, 0xa5, 0x4e, 0x6f, 0x6e, 0x63, 0x65)
+	o = msgp.AppendString(o, z.Nonce)
+	// string "ID"
+	o = append(o, 0xa2, 0x49, 0x44)
+	o = msgp.AppendUint64(o, z.ID)
+	// string "Timestamp"
+	o = append(o, 0xa9, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	o = msgp.AppendInt64(o, z.Timestamp)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Ack) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Nonce":
+			z.Nonce, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Nonce")
+				return
+			}
+		case "ID":
+			z.ID, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
+		case "Timestamp":
+			z.Timestamp, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Timestamp")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Ack) Msgsize() (s int) {
+	s = 1 + 6 + msgp.StringPrefixSize + len(z.Nonce) + 3 + msgp.Uint64Size + 10 + msgp.Int64Size
+	return
+}
+
 // DecodeMsg implements msgp.Decodable
 func (z *Action) DecodeMsg(dc *msgp.Reader) (err error) {
 	var field []byte
@@ -40,6 +193,12 @@ func (z *Action) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "Data")
 				return
 			}
+		case "ID":
+			z.ID, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -53,9 +212,9 @@ func (z *Action) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Action) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 2
+	// map header, size 3
 	// write "Type"
-	err = en.Append(0x82, 0xa4, 0x54, 0x79, 0x70, 0x65)
+	err = en.Append(0x83, 0xa4, 0x54, 0x79, 0x70, 0x65)
 	if err != nil {
 		return
 	}
@@ -74,19 +233,32 @@ func (z *Action) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "Data")
 		return
 	}
+	// write "ID"
+	err = en.Append(0xa2, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.ID)
+	if err != nil {
+		err = msgp.WrapError(err, "ID")
+		return
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Action) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 2
+	// map header, size 3
 	// string "Type"
-	o = append(o, 0x82, 0xa4, 0x54, 0x79, 0x70, 0x65)
+	o = append(o, 0x83, 0xa4, 0x54, 0x79, 0x70, 0x65)
 	o = msgp.AppendInt(o, int(z.Type))
 	// string "Data"
 	o = append(o, 0xa4, 0x44, 0x61, 0x74, 0x61)
 	o = msgp.AppendBytes(o, z.Data)
+	// string "ID"
+	o = append(o, 0xa2, 0x49, 0x44)
+	o = msgp.AppendString(o, z.ID)
 	return
 }
 
@@ -124,6 +296,12 @@ func (z *Action) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "Data")
 				return
 			}
+		case "ID":
+			z.ID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -138,7 +316,7 @@ func (z *Action) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Action) Msgsize() (s int) {
-	s = 1 + 5 + msgp.IntSize + 5 + msgp.BytesPrefixSize + len(z.Data)
+	s = 1 + 5 + msgp.IntSize + 5 + msgp.BytesPrefixSize + len(z.Data) + 3 + msgp.StringPrefixSize + len(z.ID)
 	return
 }
 
@@ -195,7 +373,7 @@ func (z ActionType) Msgsize() (s int) {
 }
 
 // DecodeMsg implements msgp.Decodable
-func (z *ErrorMessage) DecodeMsg(dc *msgp.Reader) (err error) {
+func (z *Credentials) DecodeMsg(dc *msgp.Reader) (err error) {
 	var field []byte
 	_ = field
 	var zb0001 uint32
@@ -212,10 +390,22 @@ func (z *ErrorMessage) DecodeMsg(dc *msgp.Reader) (err error) {
 			return
 		}
 		switch msgp.UnsafeString(field) {
-		case "Value":
-			z.Value, err = dc.ReadString()
+		case "Username":
+			z.Username, err = dc.ReadString()
 			if err != nil {
-				err = msgp.WrapError(err, "Value")
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Password":
+			z.Password, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Password")
+				return
+			}
+		case "Token":
+			z.Token, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Token")
 				return
 			}
 		default:
@@ -230,33 +420,59 @@ func (z *ErrorMessage) DecodeMsg(dc *msgp.Reader) (err error) {
 }
 
 // EncodeMsg implements msgp.Encodable
-func (z ErrorMessage) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 1
-	// write "Value"
-	err = en.Append(0x81, 0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
+func (z Credentials) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "Username"
+	err = en.Append(0x83, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
 	if err != nil {
 		return
 	}
-	err = en.WriteString(z.Value)
+	err = en.WriteString(z.Username)
 	if err != nil {
-		err = msgp.WrapError(err, "Value")
+		err = msgp.WrapError(err, "Username")
+		return
+	}
+	// write "Password"
+	err = en.Append(0xa8, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Password)
+	if err != nil {
+		err = msgp.WrapError(err, "Password")
+		return
+	}
+	// write "Token"
+	err = en.Append(0xa5, 0x54, 0x6f, 0x6b, 0x65, 0x6e)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Token)
+	if err != nil {
+		err = msgp.WrapError(err, "Token")
 		return
 	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
-func (z ErrorMessage) MarshalMsg(b []byte) (o []byte, err error) {
+func (z Credentials) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 1
-	// string "Value"
-	o = append(o, 0x81, 0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
-	o = msgp.AppendString(o, z.Value)
+	// map header, size 3
+	// string "Username"
+	o = append(o, 0x83, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.Username)
+	// string "Password"
+	o = append(o, 0xa8, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64)
+	o = msgp.AppendString(o, z.Password)
+	// string "Token"
+	o = append(o, 0xa5, 0x54, 0x6f, 0x6b, 0x65, 0x6e)
+	o = msgp.AppendString(o, z.Token)
 	return
 }
 
 // UnmarshalMsg implements msgp.Unmarshaler
-func (z *ErrorMessage) UnmarshalMsg(bts []byte) (o []byte, err error) {
+func (z *Credentials) UnmarshalMsg(bts []byte) (o []byte, err error) {
 	var field []byte
 	_ = field
 	var zb0001 uint32
@@ -273,10 +489,22 @@ func (z *ErrorMessage) UnmarshalMsg(bts []byte) (o []byte, err error) {
 			return
 		}
 		switch msgp.UnsafeString(field) {
-		case "Value":
-			z.Value, bts, err = msgp.ReadStringBytes(bts)
+		case "Username":
+			z.Username, bts, err = msgp.ReadStringBytes(bts)
 			if err != nil {
-				err = msgp.WrapError(err, "Value")
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Password":
+			z.Password, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Password")
+				return
+			}
+		case "Token":
+			z.Token, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Token")
 				return
 			}
 		default:
@@ -292,13 +520,13 @@ func (z *ErrorMessage) UnmarshalMsg(bts []byte) (o []byte, err error) {
 }
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
-func (z ErrorMessage) Msgsize() (s int) {
-	s = 1 + 6 + msgp.StringPrefixSize + len(z.Value)
+func (z Credentials) Msgsize() (s int) {
+	s = 1 + 9 + msgp.StringPrefixSize + len(z.Username) + 9 + msgp.StringPrefixSize + len(z.Password) + 6 + msgp.StringPrefixSize + len(z.Token)
 	return
 }
 
 // DecodeMsg implements msgp.Decodable
-func (z *Message) DecodeMsg(dc *msgp.Reader) (err error) {
+func (z *DirectMessage) DecodeMsg(dc *msgp.Reader) (err error) {
 	var field []byte
 	_ = field
 	var zb0001 uint32
@@ -321,12 +549,36 @@ func (z *Message) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "UserID")
 				return
 			}
+		case "Username":
+			z.Username, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "To":
+			z.To, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "To")
+				return
+			}
 		case "Value":
 			z.Value, err = dc.ReadString()
 			if err != nil {
 				err = msgp.WrapError(err, "Value")
 				return
 			}
+		case "Timestamp":
+			z.Timestamp, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Timestamp")
+				return
+			}
+		case "Nonce":
+			z.Nonce, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Nonce")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -339,10 +591,10 @@ func (z *Message) DecodeMsg(dc *msgp.Reader) (err error) {
 }
 
 // EncodeMsg implements msgp.Encodable
-func (z Message) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 2
+func (z *DirectMessage) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 6
 	// write "UserID"
-	err = en.Append(0x82, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	err = en.Append(0x86, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
 	if err != nil {
 		return
 	}
@@ -351,6 +603,26 @@ func (z Message) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "UserID")
 		return
 	}
+	// write "Username"
+	err = en.Append(0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Username)
+	if err != nil {
+		err = msgp.WrapError(err, "Username")
+		return
+	}
+	// write "To"
+	err = en.Append(0xa2, 0x54, 0x6f)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.To)
+	if err != nil {
+		err = msgp.WrapError(err, "To")
+		return
+	}
 	// write "Value"
 	err = en.Append(0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
 	if err != nil {
@@ -361,24 +633,56 @@ func (z Message) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "Value")
 		return
 	}
+	// write "Timestamp"
+	err = en.Append(0xa9, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.Timestamp)
+	if err != nil {
+		err = msgp.WrapError(err, "Timestamp")
+		return
+	}
+	// write "Nonce"
+	err = en.Append(0xa5, 0x4e, 0x6f, 0x6e, 0x63, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Nonce)
+	if err != nil {
+		err = msgp.WrapError(err, "Nonce")
+		return
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
-func (z Message) MarshalMsg(b []byte) (o []byte, err error) {
+func (z *DirectMessage) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 2
+	// map header, size 6
 	// string "UserID"
-	o = append(o, 0x82, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	o = append(o, 0x86, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
 	o = msgp.AppendString(o, z.UserID)
+	// string "Username"
+	o = append(o, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.Username)
+	// string "To"
+	o = append(o, 0xa2, 0x54, 0x6f)
+	o = msgp.AppendString(o, z.To)
 	// string "Value"
 	o = append(o, 0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
 	o = msgp.AppendString(o, z.Value)
+	// string "Timestamp"
+	o = append(o, 0xa9, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	o = msgp.AppendInt64(o, z.Timestamp)
+	// string "Nonce"
+	o = append(o, 0xa5, 0x4e, 0x6f, 0x6e, 0x63, 0x65)
+	o = msgp.AppendString(o, z.Nonce)
 	return
 }
 
 // UnmarshalMsg implements msgp.Unmarshaler
-func (z *Message) UnmarshalMsg(bts []byte) (o []byte, err error) {
+func (z *DirectMessage) UnmarshalMsg(bts []byte) (o []byte, err error) {
 	var field []byte
 	_ = field
 	var zb0001 uint32
@@ -401,12 +705,2626 @@ func (z *Message) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "UserID")
 				return
 			}
+		case "Username":
+			z.Username, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "To":
+			z.To, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "To")
+				return
+			}
 		case "Value":
 			z.Value, bts, err = msgp.ReadStringBytes(bts)
 			if err != nil {
 				err = msgp.WrapError(err, "Value")
 				return
 			}
+		case "Timestamp":
+			z.Timestamp, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Timestamp")
+				return
+			}
+		case "Nonce":
+			z.Nonce, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Nonce")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *DirectMessage) Msgsize() (s int) {
+	s = 1 + 7 + msgp.StringPrefixSize + len(z.UserID) + 9 + msgp.StringPrefixSize + len(z.Username) + 3 + msgp.StringPrefixSize + len(z.To) + 6 + msgp.StringPrefixSize + len(z.Value) + 10 + msgp.Int64Size + 6 + msgp.StringPrefixSize + len(z.Nonce)
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *ErrorCode) DecodeMsg(dc *msgp.Reader) (err error) {
+	{
+		var zb0001 int
+		zb0001, err = dc.ReadInt()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		(*z) = ErrorCode(zb0001)
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z ErrorCode) EncodeMsg(en *msgp.Writer) (err error) {
+	err = en.WriteInt(int(z))
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z ErrorCode) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendInt(o, int(z))
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *ErrorCode) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	{
+		var zb0001 int
+		zb0001, bts, err = msgp.ReadIntBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		(*z) = ErrorCode(zb0001)
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z ErrorCode) Msgsize() (s int) {
+	s = msgp.IntSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *ErrorMessage) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Code":
+			{
+				var zb0002 int
+				zb0002, err = dc.ReadInt()
+				if err != nil {
+					err = msgp.WrapError(err, "Code")
+					return
+				}
+				z.Code = ErrorCode(zb0002)
+			}
+		case "Value":
+			z.Value, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Value")
+				return
+			}
+		case "ID":
+			z.ID, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z ErrorMessage) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "Code"
+	err = en.Append(0x83, 0xa4, 0x43, 0x6f, 0x64, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt(int(z.Code))
+	if err != nil {
+		err = msgp.WrapError(err, "Code")
+		return
+	}
+	// write "Value"
+	err = en.Append(0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Value)
+	if err != nil {
+		err = msgp.WrapError(err, "Value")
+		return
+	}
+	// write "ID"
+	err = en.Append(0xa2, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.ID)
+	if err != nil {
+		err = msgp.WrapError(err, "ID")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z ErrorMessage) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "Code"
+	o = append(o, 0x83, 0xa4, 0x43, 0x6f, 0x64, 0x65)
+	o = msgp.AppendInt(o, int(z.Code))
+	// string "Value"
+	o = append(o, 0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
+	o = msgp.AppendString(o, z.Value)
+	// string "ID"
+	o = append(o, 0xa2, 0x49, 0x44)
+	o = msgp.AppendString(o, z.ID)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *ErrorMessage) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Code":
+			{
+				var zb0002 int
+				zb0002, bts, err = msgp.ReadIntBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Code")
+					return
+				}
+				z.Code = ErrorCode(zb0002)
+			}
+		case "Value":
+			z.Value, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Value")
+				return
+			}
+		case "ID":
+			z.ID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z ErrorMessage) Msgsize() (s int) {
+	s = 1 + 5 + msgp.IntSize + 6 + msgp.StringPrefixSize + len(z.Value) + 3 + msgp.StringPrefixSize + len(z.ID)
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *History) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Room":
+			z.Room, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Messages":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Messages")
+				return
+			}
+			if cap(z.Messages) >= int(zb0002) {
+				z.Messages = (z.Messages)[:zb0002]
+			} else {
+				z.Messages = make([]Message, zb0002)
+			}
+			for za0001 := range z.Messages {
+				err = z.Messages[za0001].DecodeMsg(dc)
+				if err != nil {
+					err = msgp.WrapError(err, "Messages", za0001)
+					return
+				}
+			}
+		case "Before":
+			z.Before, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "Before")
+				return
+			}
+		case "More":
+			z.More, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "More")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *History) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 4
+	// write "Room"
+	err = en.Append(0x84, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Room)
+	if err != nil {
+		err = msgp.WrapError(err, "Room")
+		return
+	}
+	// write "Messages"
+	err = en.Append(0xa8, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.Messages)))
+	if err != nil {
+		err = msgp.WrapError(err, "Messages")
+		return
+	}
+	for za0001 := range z.Messages {
+		err = z.Messages[za0001].EncodeMsg(en)
+		if err != nil {
+			err = msgp.WrapError(err, "Messages", za0001)
+			return
+		}
+	}
+	// write "Before"
+	err = en.Append(0xa6, 0x42, 0x65, 0x66, 0x6f, 0x72, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.Before)
+	if err != nil {
+		err = msgp.WrapError(err, "Before")
+		return
+	}
+	// write "More"
+	err = en.Append(0xa4, 0x4d, 0x6f, 0x72, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteBool(z.More)
+	if err != nil {
+		err = msgp.WrapError(err, "More")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *History) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 4
+	// string "Room"
+	o = append(o, 0x84, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	o = msgp.AppendString(o, z.Room)
+	// string "Messages"
+	o = append(o, 0xa8, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.Messages)))
+	for za0001 := range z.Messages {
+		o, err = z.Messages[za0001].MarshalMsg(o)
+		if err != nil {
+			err = msgp.WrapError(err, "Messages", za0001)
+			return
+		}
+	}
+	// string "Before"
+	o = append(o, 0xa6, 0x42, 0x65, 0x66, 0x6f, 0x72, 0x65)
+	o = msgp.AppendUint64(o, z.Before)
+	// string "More"
+	o = append(o, 0xa4, 0x4d, 0x6f, 0x72, 0x65)
+	o = msgp.AppendBool(o, z.More)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *History) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Room":
+			z.Room, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Messages":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Messages")
+				return
+			}
+			if cap(z.Messages) >= int(zb0002) {
+				z.Messages = (z.Messages)[:zb0002]
+			} else {
+				z.Messages = make([]Message, zb0002)
+			}
+			for za0001 := range z.Messages {
+				bts, err = z.Messages[za0001].UnmarshalMsg(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Messages", za0001)
+					return
+				}
+			}
+		case "Before":
+			z.Before, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Before")
+				return
+			}
+		case "More":
+			z.More, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "More")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *History) Msgsize() (s int) {
+	s = 1 + 5 + msgp.StringPrefixSize + len(z.Room) + 9 + msgp.ArrayHeaderSize
+	for za0001 := range z.Messages {
+		s += z.Messages[za0001].Msgsize()
+	}
+	s += 7 + msgp.Uint64Size + 5 + msgp.BoolSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *HistoryRequest) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Room":
+			z.Room, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Before":
+			z.Before, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "Before")
+				return
+			}
+		case "Limit":
+			z.Limit, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "Limit")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z HistoryRequest) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "Room"
+	err = en.Append(0x83, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Room)
+	if err != nil {
+		err = msgp.WrapError(err, "Room")
+		return
+	}
+	// write "Before"
+	err = en.Append(0xa6, 0x42, 0x65, 0x66, 0x6f, 0x72, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.Before)
+	if err != nil {
+		err = msgp.WrapError(err, "Before")
+		return
+	}
+	// write "Limit"
+	err = en.Append(0xa5, 0x4c, 0x69, 0x6d, 0x69, 0x74)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt(z.Limit)
+	if err != nil {
+		err = msgp.WrapError(err, "Limit")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z HistoryRequest) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "Room"
+	o = append(o, 0x83, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	o = msgp.AppendString(o, z.Room)
+	// string "Before"
+	o = append(o, 0xa6, 0x42, 0x65, 0x66, 0x6f, 0x72, 0x65)
+	o = msgp.AppendUint64(o, z.Before)
+	// string "Limit"
+	o = append(o, 0xa5, 0x4c, 0x69, 0x6d, 0x69, 0x74)
+	o = msgp.AppendInt(o, z.Limit)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *HistoryRequest) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Room":
+			z.Room, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Before":
+			z.Before, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Before")
+				return
+			}
+		case "Limit":
+			z.Limit, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Limit")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z HistoryRequest) Msgsize() (s int) {
+	s = 1 + 5 + msgp.StringPrefixSize + len(z.Room) + 7 + msgp.Uint64Size + 6 + msgp.IntSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Message) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "ID":
+			z.ID, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
+		case "UserID":
+			z.UserID, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "UserID")
+				return
+			}
+		case "Username":
+			z.Username, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Room":
+			z.Room, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Value":
+			z.Value, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Value")
+				return
+			}
+		case "Timestamp":
+			z.Timestamp, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "Timestamp")
+				return
+			}
+		case "Nonce":
+			z.Nonce, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Nonce")
+				return
+			}
+		case "Edited":
+			z.Edited, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Edited")
+				return
+			}
+		case "Deleted":
+			z.Deleted, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Deleted")
+				return
+			}
+		case "Reactions":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Reactions")
+				return
+			}
+			if cap(z.Reactions) >= int(zb0002) {
+				z.Reactions = (z.Reactions)[:zb0002]
+			} else {
+				z.Reactions = make([]ReactionCount, zb0002)
+			}
+			for za0001 := range z.Reactions {
+				err = z.Reactions[za0001].DecodeMsg(dc)
+				if err != nil {
+					err = msgp.WrapError(err, "Reactions", za0001)
+					return
+				}
+			}
+		case "ReplyTo":
+			z.ReplyTo, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "ReplyTo")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *Message) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 11
+	// write "ID"
+	err = en.Append(0x8b, 0xa2, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.ID)
+	if err != nil {
+		err = msgp.WrapError(err, "ID")
+		return
+	}
+	// write "UserID"
+	err = en.Append(0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.UserID)
+	if err != nil {
+		err = msgp.WrapError(err, "UserID")
+		return
+	}
+	// write "Username"
+	err = en.Append(0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Username)
+	if err != nil {
+		err = msgp.WrapError(err, "Username")
+		return
+	}
+	// write "Room"
+	err = en.Append(0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Room)
+	if err != nil {
+		err = msgp.WrapError(err, "Room")
+		return
+	}
+	// write "Value"
+	err = en.Append(0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Value)
+	if err != nil {
+		err = msgp.WrapError(err, "Value")
+		return
+	}
+	// write "Timestamp"
+	err = en.Append(0xa9, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt64(z.Timestamp)
+	if err != nil {
+		err = msgp.WrapError(err, "Timestamp")
+		return
+	}
+	// write "Nonce"
+	err = en.Append(0xa5, 0x4e, 0x6f, 0x6e, 0x63, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Nonce)
+	if err != nil {
+		err = msgp.WrapError(err, "Nonce")
+		return
+	}
+	// write "Edited"
+	err = en.Append(0xa6, 0x45, 0x64, 0x69, 0x74, 0x65, 0x64)
+	if err != nil {
+		return
+	}
+	err = en.WriteBool(z.Edited)
+	if err != nil {
+		err = msgp.WrapError(err, "Edited")
+		return
+	}
+	// write "Deleted"
+	err = en.Append(0xa7, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64)
+	if err != nil {
+		return
+	}
+	err = en.WriteBool(z.Deleted)
+	if err != nil {
+		err = msgp.WrapError(err, "Deleted")
+		return
+	}
+	// write "Reactions"
+	err = en.Append(0xa9, 0x52, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.Reactions)))
+	if err != nil {
+		err = msgp.WrapError(err, "Reactions")
+		return
+	}
+	for za0001 := range z.Reactions {
+		err = z.Reactions[za0001].EncodeMsg(en)
+		if err != nil {
+			err = msgp.WrapError(err, "Reactions", za0001)
+			return
+		}
+	}
+	// write "ReplyTo"
+	err = en.Append(0xa7, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.ReplyTo)
+	if err != nil {
+		err = msgp.WrapError(err, "ReplyTo")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *Message) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 11
+	// string "ID"
+	o = append(o, 0x8b, 0xa2, 0x49, 0x44)
+	o = msgp.AppendUint64(o, z.ID)
+	// string "UserID"
+	o = append(o, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	o = msgp.AppendString(o, z.UserID)
+	// string "Username"
+	o = append(o, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.Username)
+	// string "Room"
+	o = append(o, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	o = msgp.AppendString(o, z.Room)
+	// string "Value"
+	o = append(o, 0xa5, 0x56, 0x61, 0x6c, 0x75, 0x65)
+	o = msgp.AppendString(o, z.Value)
+	// string "Timestamp"
+	o = append(o, 0xa9, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70)
+	o = msgp.AppendInt64(o, z.Timestamp)
+	// string "Nonce"
+	o = append(o, 0xa5, 0x4e, 0x6f, 0x6e, 0x63, 0x65)
+	o = msgp.AppendString(o, z.Nonce)
+	// string "Edited"
+	o = append(o, 0xa6, 0x45, 0x64, 0x69, 0x74, 0x65, 0x64)
+	o = msgp.AppendBool(o, z.Edited)
+	// string "Deleted"
+	o = append(o, 0xa7, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64)
+	o = msgp.AppendBool(o, z.Deleted)
+	// string "Reactions"
+	o = append(o, 0xa9, 0x52, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.Reactions)))
+	for za0001 := range z.Reactions {
+		o, err = z.Reactions[za0001].MarshalMsg(o)
+		if err != nil {
+			err = msgp.WrapError(err, "Reactions", za0001)
+			return
+		}
+	}
+	// string "ReplyTo"
+	o = append(o, 0xa7, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x54, 0x6f)
+	o = msgp.AppendUint64(o, z.ReplyTo)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Message) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "ID":
+			z.ID, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ID")
+				return
+			}
+		case "UserID":
+			z.UserID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "UserID")
+				return
+			}
+		case "Username":
+			z.Username, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Room":
+			z.Room, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Value":
+			z.Value, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Value")
+				return
+			}
+		case "Timestamp":
+			z.Timestamp, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Timestamp")
+				return
+			}
+		case "Nonce":
+			z.Nonce, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Nonce")
+				return
+			}
+		case "Edited":
+			z.Edited, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Edited")
+				return
+			}
+		case "Deleted":
+			z.Deleted, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Deleted")
+				return
+			}
+		case "Reactions":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Reactions")
+				return
+			}
+			if cap(z.Reactions) >= int(zb0002) {
+				z.Reactions = (z.Reactions)[:zb0002]
+			} else {
+				z.Reactions = make([]ReactionCount, zb0002)
+			}
+			for za0001 := range z.Reactions {
+				bts, err = z.Reactions[za0001].UnmarshalMsg(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Reactions", za0001)
+					return
+				}
+			}
+		case "ReplyTo":
+			z.ReplyTo, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ReplyTo")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *Message) Msgsize() (s int) {
+	s = 1 + 3 + msgp.Uint64Size + 7 + msgp.StringPrefixSize + len(z.UserID) + 9 + msgp.StringPrefixSize + len(z.Username) + 5 + msgp.StringPrefixSize + len(z.Room) + 6 + msgp.StringPrefixSize + len(z.Value) + 10 + msgp.Int64Size + 6 + msgp.StringPrefixSize + len(z.Nonce) + 7 + msgp.BoolSize + 8 + msgp.BoolSize + 10 + msgp.ArrayHeaderSize
+	for za0001 := range z.Reactions {
+		s += z.Reactions[za0001].Msgsize()
+	}
+	s += 8 + msgp.Uint64Size
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Presence) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Type":
+			{
+				var zb0002 int
+				zb0002, err = dc.ReadInt()
+				if err != nil {
+					err = msgp.WrapError(err, "Type")
+					return
+				}
+				z.Type = PresenceType(zb0002)
+			}
+		case "User":
+			var zb0003 uint32
+			zb0003, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "User")
+				return
+			}
+			for zb0003 > 0 {
+				zb0003--
+				field, err = dc.ReadMapKeyPtr()
+				if err != nil {
+					err = msgp.WrapError(err, "User")
+					return
+				}
+				switch msgp.UnsafeString(field) {
+				case "ID":
+					z.User.ID, err = dc.ReadString()
+					if err != nil {
+						err = msgp.WrapError(err, "User", "ID")
+						return
+					}
+				case "Username":
+					z.User.Username, err = dc.ReadString()
+					if err != nil {
+						err = msgp.WrapError(err, "User", "Username")
+						return
+					}
+				default:
+					err = dc.Skip()
+					if err != nil {
+						err = msgp.WrapError(err, "User")
+						return
+					}
+				}
+			}
+		case "Room":
+			z.Room, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Previous":
+			z.Previous, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Previous")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *Presence) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 4
+	// write "Type"
+	err = en.Append(0x84, 0xa4, 0x54, 0x79, 0x70, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt(int(z.Type))
+	if err != nil {
+		err = msgp.WrapError(err, "Type")
+		return
+	}
+	// write "User"
+	err = en.Append(0xa4, 0x55, 0x73, 0x65, 0x72)
+	if err != nil {
+		return
+	}
+	// map header, size 2
+	// write "ID"
+	err = en.Append(0x82, 0xa2, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.User.ID)
+	if err != nil {
+		err = msgp.WrapError(err, "User", "ID")
+		return
+	}
+	// write "Username"
+	err = en.Append(0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.User.Username)
+	if err != nil {
+		err = msgp.WrapError(err, "User", "Username")
+		return
+	}
+	// write "Room"
+	err = en.Append(0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Room)
+	if err != nil {
+		err = msgp.WrapError(err, "Room")
+		return
+	}
+	// write "Previous"
+	err = en.Append(0xa8, 0x50, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Previous)
+	if err != nil {
+		err = msgp.WrapError(err, "Previous")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *Presence) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 4
+	// string "Type"
+	o = append(o, 0x84, 0xa4, 0x54, 0x79, 0x70, 0x65)
+	o = msgp.AppendInt(o, int(z.Type))
+	// string "User"
+	o = append(o, 0xa4, 0x55, 0x73, 0x65, 0x72)
+	// map header, size 2
+	// string "ID"
+	o = append(o, 0x82, 0xa2, 0x49, 0x44)
+	o = msgp.AppendString(o, z.User.ID)
+	// string "Username"
+	o = append(o, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.User.Username)
+	// string "Room"
+	o = append(o, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	o = msgp.AppendString(o, z.Room)
+	// string "Previous"
+	o = append(o, 0xa8, 0x50, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73)
+	o = msgp.AppendString(o, z.Previous)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Presence) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Type":
+			{
+				var zb0002 int
+				zb0002, bts, err = msgp.ReadIntBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Type")
+					return
+				}
+				z.Type = PresenceType(zb0002)
+			}
+		case "User":
+			var zb0003 uint32
+			zb0003, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "User")
+				return
+			}
+			for zb0003 > 0 {
+				zb0003--
+				field, bts, err = msgp.ReadMapKeyZC(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "User")
+					return
+				}
+				switch msgp.UnsafeString(field) {
+				case "ID":
+					z.User.ID, bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "User", "ID")
+						return
+					}
+				case "Username":
+					z.User.Username, bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "User", "Username")
+						return
+					}
+				default:
+					bts, err = msgp.Skip(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "User")
+						return
+					}
+				}
+			}
+		case "Room":
+			z.Room, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		case "Previous":
+			z.Previous, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Previous")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *Presence) Msgsize() (s int) {
+	s = 1 + 5 + msgp.IntSize + 5 + 1 + 3 + msgp.StringPrefixSize + len(z.User.ID) + 9 + msgp.StringPrefixSize + len(z.User.Username) + 5 + msgp.StringPrefixSize + len(z.Room) + 9 + msgp.StringPrefixSize + len(z.Previous)
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *PresenceType) DecodeMsg(dc *msgp.Reader) (err error) {
+	{
+		var zb0001 int
+		zb0001, err = dc.ReadInt()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		(*z) = PresenceType(zb0001)
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z PresenceType) EncodeMsg(en *msgp.Writer) (err error) {
+	err = en.WriteInt(int(z))
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z PresenceType) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendInt(o, int(z))
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *PresenceType) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	{
+		var zb0001 int
+		zb0001, bts, err = msgp.ReadIntBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		(*z) = PresenceType(zb0001)
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z PresenceType) Msgsize() (s int) {
+	s = msgp.IntSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Reaction) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "MessageID":
+			z.MessageID, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "MessageID")
+				return
+			}
+		case "Emoji":
+			z.Emoji, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Emoji")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z Reaction) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 2
+	// write "MessageID"
+	err = en.Append(0x82, 0xa9, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteUint64(z.MessageID)
+	if err != nil {
+		err = msgp.WrapError(err, "MessageID")
+		return
+	}
+	// write "Emoji"
+	err = en.Append(0xa5, 0x45, 0x6d, 0x6f, 0x6a, 0x69)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Emoji)
+	if err != nil {
+		err = msgp.WrapError(err, "Emoji")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Reaction) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 2
+	// string "MessageID"
+	o = append(o, 0x82, 0xa9, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x44)
+	o = msgp.AppendUint64(o, z.MessageID)
+	// string "Emoji"
+	o = append(o, 0xa5, 0x45, 0x6d, 0x6f, 0x6a, 0x69)
+	o = msgp.AppendString(o, z.Emoji)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Reaction) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "MessageID":
+			z.MessageID, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "MessageID")
+				return
+			}
+		case "Emoji":
+			z.Emoji, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Emoji")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Reaction) Msgsize() (s int) {
+	s = 1 + 10 + msgp.Uint64Size + 6 + msgp.StringPrefixSize + len(z.Emoji)
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *ReactionCount) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Emoji":
+			z.Emoji, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Emoji")
+				return
+			}
+		case "Count":
+			z.Count, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "Count")
+				return
+			}
+		case "UserIDs":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "UserIDs")
+				return
+			}
+			if cap(z.UserIDs) >= int(zb0002) {
+				z.UserIDs = (z.UserIDs)[:zb0002]
+			} else {
+				z.UserIDs = make([]string, zb0002)
+			}
+			for za0001 := range z.UserIDs {
+				z.UserIDs[za0001], err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "UserIDs", za0001)
+					return
+				}
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *ReactionCount) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "Emoji"
+	err = en.Append(0x83, 0xa5, 0x45, 0x6d, 0x6f, 0x6a, 0x69)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Emoji)
+	if err != nil {
+		err = msgp.WrapError(err, "Emoji")
+		return
+	}
+	// write "Count"
+	err = en.Append(0xa5, 0x43, 0x6f, 0x75, 0x6e, 0x74)
+	if err != nil {
+		return
+	}
+	err = en.WriteInt(z.Count)
+	if err != nil {
+		err = msgp.WrapError(err, "Count")
+		return
+	}
+	// write "UserIDs"
+	err = en.Append(0xa7, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.UserIDs)))
+	if err != nil {
+		err = msgp.WrapError(err, "UserIDs")
+		return
+	}
+	for za0001 := range z.UserIDs {
+		err = en.WriteString(z.UserIDs[za0001])
+		if err != nil {
+			err = msgp.WrapError(err, "UserIDs", za0001)
+			return
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *ReactionCount) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "Emoji"
+	o = append(o, 0x83, 0xa5, 0x45, 0x6d, 0x6f, 0x6a, 0x69)
+	o = msgp.AppendString(o, z.Emoji)
+	// string "Count"
+	o = append(o, 0xa5, 0x43, 0x6f, 0x75, 0x6e, 0x74)
+	o = msgp.AppendInt(o, z.Count)
+	// string "UserIDs"
+	o = append(o, 0xa7, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44, 0x73)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.UserIDs)))
+	for za0001 := range z.UserIDs {
+		o = msgp.AppendString(o, z.UserIDs[za0001])
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *ReactionCount) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Emoji":
+			z.Emoji, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Emoji")
+				return
+			}
+		case "Count":
+			z.Count, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Count")
+				return
+			}
+		case "UserIDs":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "UserIDs")
+				return
+			}
+			if cap(z.UserIDs) >= int(zb0002) {
+				z.UserIDs = (z.UserIDs)[:zb0002]
+			} else {
+				z.UserIDs = make([]string, zb0002)
+			}
+			for za0001 := range z.UserIDs {
+				z.UserIDs[za0001], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "UserIDs", za0001)
+					return
+				}
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *ReactionCount) Msgsize() (s int) {
+	s = 1 + 6 + msgp.StringPrefixSize + len(z.Emoji) + 6 + msgp.IntSize + 8 + msgp.ArrayHeaderSize
+	for za0001 := range z.UserIDs {
+		s += msgp.StringPrefixSize + len(z.UserIDs[za0001])
+	}
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Register) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Username":
+			z.Username, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Token":
+			z.Token, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Token")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z Register) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 2
+	// write "Username"
+	err = en.Append(0x82, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Username)
+	if err != nil {
+		err = msgp.WrapError(err, "Username")
+		return
+	}
+	// write "Token"
+	err = en.Append(0xa5, 0x54, 0x6f, 0x6b, 0x65, 0x6e)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Token)
+	if err != nil {
+		err = msgp.WrapError(err, "Token")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Register) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 2
+	// string "Username"
+	o = append(o, 0x82, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.Username)
+	// string "Token"
+	o = append(o, 0xa5, 0x54, 0x6f, 0x6b, 0x65, 0x6e)
+	o = msgp.AppendString(o, z.Token)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Register) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Username":
+			z.Username, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Token":
+			z.Token, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Token")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Register) Msgsize() (s int) {
+	s = 1 + 9 + msgp.StringPrefixSize + len(z.Username) + 6 + msgp.StringPrefixSize + len(z.Token)
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Room) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Name":
+			z.Name, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Name")
+				return
+			}
+		case "Users":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Users")
+				return
+			}
+			if cap(z.Users) >= int(zb0002) {
+				z.Users = (z.Users)[:zb0002]
+			} else {
+				z.Users = make(Users, zb0002)
+			}
+			for za0001 := range z.Users {
+				var zb0003 uint32
+				zb0003, err = dc.ReadMapHeader()
+				if err != nil {
+					err = msgp.WrapError(err, "Users", za0001)
+					return
+				}
+				for zb0003 > 0 {
+					zb0003--
+					field, err = dc.ReadMapKeyPtr()
+					if err != nil {
+						err = msgp.WrapError(err, "Users", za0001)
+						return
+					}
+					switch msgp.UnsafeString(field) {
+					case "ID":
+						z.Users[za0001].ID, err = dc.ReadString()
+						if err != nil {
+							err = msgp.WrapError(err, "Users", za0001, "ID")
+							return
+						}
+					case "Username":
+						z.Users[za0001].Username, err = dc.ReadString()
+						if err != nil {
+							err = msgp.WrapError(err, "Users", za0001, "Username")
+							return
+						}
+					default:
+						err = dc.Skip()
+						if err != nil {
+							err = msgp.WrapError(err, "Users", za0001)
+							return
+						}
+					}
+				}
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *Room) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 2
+	// write "Name"
+	err = en.Append(0x82, 0xa4, 0x4e, 0x61, 0x6d, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Name)
+	if err != nil {
+		err = msgp.WrapError(err, "Name")
+		return
+	}
+	// write "Users"
+	err = en.Append(0xa5, 0x55, 0x73, 0x65, 0x72, 0x73)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.Users)))
+	if err != nil {
+		err = msgp.WrapError(err, "Users")
+		return
+	}
+	for za0001 := range z.Users {
+		// map header, size 2
+		// write "ID"
+		err = en.Append(0x82, 0xa2, 0x49, 0x44)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Users[za0001].ID)
+		if err != nil {
+			err = msgp.WrapError(err, "Users", za0001, "ID")
+			return
+		}
+		// write "Username"
+		err = en.Append(0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Users[za0001].Username)
+		if err != nil {
+			err = msgp.WrapError(err, "Users", za0001, "Username")
+			return
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *Room) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 2
+	// string "Name"
+	o = append(o, 0x82, 0xa4, 0x4e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.Name)
+	// string "Users"
+	o = append(o, 0xa5, 0x55, 0x73, 0x65, 0x72, 0x73)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.Users)))
+	for za0001 := range z.Users {
+		// map header, size 2
+		// string "ID"
+		o = append(o, 0x82, 0xa2, 0x49, 0x44)
+		o = msgp.AppendString(o, z.Users[za0001].ID)
+		// string "Username"
+		o = append(o, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+		o = msgp.AppendString(o, z.Users[za0001].Username)
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Room) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "Name":
+			z.Name, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Name")
+				return
+			}
+		case "Users":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Users")
+				return
+			}
+			if cap(z.Users) >= int(zb0002) {
+				z.Users = (z.Users)[:zb0002]
+			} else {
+				z.Users = make(Users, zb0002)
+			}
+			for za0001 := range z.Users {
+				var zb0003 uint32
+				zb0003, bts, err = msgp.ReadMapHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Users", za0001)
+					return
+				}
+				for zb0003 > 0 {
+					zb0003--
+					field, bts, err = msgp.ReadMapKeyZC(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "Users", za0001)
+						return
+					}
+					switch msgp.UnsafeString(field) {
+					case "ID":
+						z.Users[za0001].ID, bts, err = msgp.ReadStringBytes(bts)
+						if err != nil {
+							err = msgp.WrapError(err, "Users", za0001, "ID")
+							return
+						}
+					case "Username":
+						z.Users[za0001].Username, bts, err = msgp.ReadStringBytes(bts)
+						if err != nil {
+							err = msgp.WrapError(err, "Users", za0001, "Username")
+							return
+						}
+					default:
+						bts, err = msgp.Skip(bts)
+						if err != nil {
+							err = msgp.WrapError(err, "Users", za0001)
+							return
+						}
+					}
+				}
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *Room) Msgsize() (s int) {
+	s = 1 + 5 + msgp.StringPrefixSize + len(z.Name) + 6 + msgp.ArrayHeaderSize
+	for za0001 := range z.Users {
+		s += 1 + 3 + msgp.StringPrefixSize + len(z.Users[za0001].ID) + 9 + msgp.StringPrefixSize + len(z.Users[za0001].Username)
+	}
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Rooms) DecodeMsg(dc *msgp.Reader) (err error) {
+	var zb0003 uint32
+	zb0003, err = dc.ReadArrayHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if cap((*z)) >= int(zb0003) {
+		(*z) = (*z)[:zb0003]
+	} else {
+		(*z) = make(Rooms, zb0003)
+	}
+	for zb0001 := range *z {
+		var field []byte
+		_ = field
+		var zb0004 uint32
+		zb0004, err = dc.ReadMapHeader()
+		if err != nil {
+			err = msgp.WrapError(err, zb0001)
+			return
+		}
+		for zb0004 > 0 {
+			zb0004--
+			field, err = dc.ReadMapKeyPtr()
+			if err != nil {
+				err = msgp.WrapError(err, zb0001)
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "Name":
+				(*z)[zb0001].Name, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, zb0001, "Name")
+					return
+				}
+			case "Users":
+				var zb0005 uint32
+				zb0005, err = dc.ReadArrayHeader()
+				if err != nil {
+					err = msgp.WrapError(err, zb0001, "Users")
+					return
+				}
+				if cap((*z)[zb0001].Users) >= int(zb0005) {
+					(*z)[zb0001].Users = ((*z)[zb0001].Users)[:zb0005]
+				} else {
+					(*z)[zb0001].Users = make(Users, zb0005)
+				}
+				for zb0002 := range (*z)[zb0001].Users {
+					var zb0006 uint32
+					zb0006, err = dc.ReadMapHeader()
+					if err != nil {
+						err = msgp.WrapError(err, zb0001, "Users", zb0002)
+						return
+					}
+					for zb0006 > 0 {
+						zb0006--
+						field, err = dc.ReadMapKeyPtr()
+						if err != nil {
+							err = msgp.WrapError(err, zb0001, "Users", zb0002)
+							return
+						}
+						switch msgp.UnsafeString(field) {
+						case "ID":
+							(*z)[zb0001].Users[zb0002].ID, err = dc.ReadString()
+							if err != nil {
+								err = msgp.WrapError(err, zb0001, "Users", zb0002, "ID")
+								return
+							}
+						case "Username":
+							(*z)[zb0001].Users[zb0002].Username, err = dc.ReadString()
+							if err != nil {
+								err = msgp.WrapError(err, zb0001, "Users", zb0002, "Username")
+								return
+							}
+						default:
+							err = dc.Skip()
+							if err != nil {
+								err = msgp.WrapError(err, zb0001, "Users", zb0002)
+								return
+							}
+						}
+					}
+				}
+			default:
+				err = dc.Skip()
+				if err != nil {
+					err = msgp.WrapError(err, zb0001)
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z Rooms) EncodeMsg(en *msgp.Writer) (err error) {
+	err = en.WriteArrayHeader(uint32(len(z)))
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0007 := range z {
+		// map header, size 2
+		// write "Name"
+		err = en.Append(0x82, 0xa4, 0x4e, 0x61, 0x6d, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z[zb0007].Name)
+		if err != nil {
+			err = msgp.WrapError(err, zb0007, "Name")
+			return
+		}
+		// write "Users"
+		err = en.Append(0xa5, 0x55, 0x73, 0x65, 0x72, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteArrayHeader(uint32(len(z[zb0007].Users)))
+		if err != nil {
+			err = msgp.WrapError(err, zb0007, "Users")
+			return
+		}
+		for zb0008 := range z[zb0007].Users {
+			// map header, size 2
+			// write "ID"
+			err = en.Append(0x82, 0xa2, 0x49, 0x44)
+			if err != nil {
+				return
+			}
+			err = en.WriteString(z[zb0007].Users[zb0008].ID)
+			if err != nil {
+				err = msgp.WrapError(err, zb0007, "Users", zb0008, "ID")
+				return
+			}
+			// write "Username"
+			err = en.Append(0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+			if err != nil {
+				return
+			}
+			err = en.WriteString(z[zb0007].Users[zb0008].Username)
+			if err != nil {
+				err = msgp.WrapError(err, zb0007, "Users", zb0008, "Username")
+				return
+			}
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Rooms) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendArrayHeader(o, uint32(len(z)))
+	for zb0007 := range z {
+		// map header, size 2
+		// string "Name"
+		o = append(o, 0x82, 0xa4, 0x4e, 0x61, 0x6d, 0x65)
+		o = msgp.AppendString(o, z[zb0007].Name)
+		// string "Users"
+		o = append(o, 0xa5, 0x55, 0x73, 0x65, 0x72, 0x73)
+		o = msgp.AppendArrayHeader(o, uint32(len(z[zb0007].Users)))
+		for zb0008 := range z[zb0007].Users {
+			// map header, size 2
+			// string "ID"
+			o = append(o, 0x82, 0xa2, 0x49, 0x44)
+			o = msgp.AppendString(o, z[zb0007].Users[zb0008].ID)
+			// string "Username"
+			o = append(o, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+			o = msgp.AppendString(o, z[zb0007].Users[zb0008].Username)
+		}
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Rooms) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0003 uint32
+	zb0003, bts, err = msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if cap((*z)) >= int(zb0003) {
+		(*z) = (*z)[:zb0003]
+	} else {
+		(*z) = make(Rooms, zb0003)
+	}
+	for zb0001 := range *z {
+		var field []byte
+		_ = field
+		var zb0004 uint32
+		zb0004, bts, err = msgp.ReadMapHeaderBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err, zb0001)
+			return
+		}
+		for zb0004 > 0 {
+			zb0004--
+			field, bts, err = msgp.ReadMapKeyZC(bts)
+			if err != nil {
+				err = msgp.WrapError(err, zb0001)
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "Name":
+				(*z)[zb0001].Name, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, zb0001, "Name")
+					return
+				}
+			case "Users":
+				var zb0005 uint32
+				zb0005, bts, err = msgp.ReadArrayHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, zb0001, "Users")
+					return
+				}
+				if cap((*z)[zb0001].Users) >= int(zb0005) {
+					(*z)[zb0001].Users = ((*z)[zb0001].Users)[:zb0005]
+				} else {
+					(*z)[zb0001].Users = make(Users, zb0005)
+				}
+				for zb0002 := range (*z)[zb0001].Users {
+					var zb0006 uint32
+					zb0006, bts, err = msgp.ReadMapHeaderBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, zb0001, "Users", zb0002)
+						return
+					}
+					for zb0006 > 0 {
+						zb0006--
+						field, bts, err = msgp.ReadMapKeyZC(bts)
+						if err != nil {
+							err = msgp.WrapError(err, zb0001, "Users", zb0002)
+							return
+						}
+						switch msgp.UnsafeString(field) {
+						case "ID":
+							(*z)[zb0001].Users[zb0002].ID, bts, err = msgp.ReadStringBytes(bts)
+							if err != nil {
+								err = msgp.WrapError(err, zb0001, "Users", zb0002, "ID")
+								return
+							}
+						case "Username":
+							(*z)[zb0001].Users[zb0002].Username, bts, err = msgp.ReadStringBytes(bts)
+							if err != nil {
+								err = msgp.WrapError(err, zb0001, "Users", zb0002, "Username")
+								return
+							}
+						default:
+							bts, err = msgp.Skip(bts)
+							if err != nil {
+								err = msgp.WrapError(err, zb0001, "Users", zb0002)
+								return
+							}
+						}
+					}
+				}
+			default:
+				bts, err = msgp.Skip(bts)
+				if err != nil {
+					err = msgp.WrapError(err, zb0001)
+					return
+				}
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Rooms) Msgsize() (s int) {
+	s = msgp.ArrayHeaderSize
+	for zb0007 := range z {
+		s += 1 + 5 + msgp.StringPrefixSize + len(z[zb0007].Name) + 6 + msgp.ArrayHeaderSize
+		for zb0008 := range z[zb0007].Users {
+			s += 1 + 3 + msgp.StringPrefixSize + len(z[zb0007].Users[zb0008].ID) + 9 + msgp.StringPrefixSize + len(z[zb0007].Users[zb0008].Username)
+		}
+	}
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Session) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "UserID":
+			z.UserID, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "UserID")
+				return
+			}
+		case "Token":
+			z.Token, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Token")
+				return
+			}
+		case "Resumed":
+			z.Resumed, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Resumed")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z Session) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "UserID"
+	err = en.Append(0x83, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.UserID)
+	if err != nil {
+		err = msgp.WrapError(err, "UserID")
+		return
+	}
+	// write "Token"
+	err = en.Append(0xa5, 0x54, 0x6f, 0x6b, 0x65, 0x6e)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Token)
+	if err != nil {
+		err = msgp.WrapError(err, "Token")
+		return
+	}
+	// write "Resumed"
+	err = en.Append(0xa7, 0x52, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x64)
+	if err != nil {
+		return
+	}
+	err = en.WriteBool(z.Resumed)
+	if err != nil {
+		err = msgp.WrapError(err, "Resumed")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Session) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "UserID"
+	o = append(o, 0x83, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	o = msgp.AppendString(o, z.UserID)
+	// string "Token"
+	o = append(o, 0xa5, 0x54, 0x6f, 0x6b, 0x65, 0x6e)
+	o = msgp.AppendString(o, z.Token)
+	// string "Resumed"
+	o = append(o, 0xa7, 0x52, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x64)
+	o = msgp.AppendBool(o, z.Resumed)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Session) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "UserID":
+			z.UserID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "UserID")
+				return
+			}
+		case "Token":
+			z.Token, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Token")
+				return
+			}
+		case "Resumed":
+			z.Resumed, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Resumed")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z Session) Msgsize() (s int) {
+	s = 1 + 7 + msgp.StringPrefixSize + len(z.UserID) + 6 + msgp.StringPrefixSize + len(z.Token) + 8 + msgp.BoolSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *Typing) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "UserID":
+			z.UserID, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "UserID")
+				return
+			}
+		case "Username":
+			z.Username, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Room":
+			z.Room, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z Typing) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 3
+	// write "UserID"
+	err = en.Append(0x83, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.UserID)
+	if err != nil {
+		err = msgp.WrapError(err, "UserID")
+		return
+	}
+	// write "Username"
+	err = en.Append(0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Username)
+	if err != nil {
+		err = msgp.WrapError(err, "Username")
+		return
+	}
+	// write "Room"
+	err = en.Append(0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.Room)
+	if err != nil {
+		err = msgp.WrapError(err, "Room")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z Typing) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 3
+	// string "UserID"
+	o = append(o, 0x83, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
+	o = msgp.AppendString(o, z.UserID)
+	// string "Username"
+	o = append(o, 0xa8, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65)
+	o = msgp.AppendString(o, z.Username)
+	// string "Room"
+	o = append(o, 0xa4, 0x52, 0x6f, 0x6f, 0x6d)
+	o = msgp.AppendString(o, z.Room)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Typing) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "UserID":
+			z.UserID, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "UserID")
+				return
+			}
+		case "Username":
+			z.Username, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Username")
+				return
+			}
+		case "Room":
+			z.Room, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Room")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -420,8 +3338,8 @@ func (z *Message) UnmarshalMsg(bts []byte) (o []byte, err error) {
 }
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
-func (z Message) Msgsize() (s int) {
-	s = 1 + 7 + msgp.StringPrefixSize + len(z.UserID) + 6 + msgp.StringPrefixSize + len(z.Value)
+func (z Typing) Msgsize() (s int) {
+	s = 1 + 7 + msgp.StringPrefixSize + len(z.UserID) + 9 + msgp.StringPrefixSize + len(z.Username) + 5 + msgp.StringPrefixSize + len(z.Room)
 	return
 }
 